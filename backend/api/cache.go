@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"smartyshop/internal"
+	"smartyshop/scrapers"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Values of the X-Cache response header, telling where products came from.
+const (
+	// CacheHit means the products were served from a fresh cache entry.
+	CacheHit = "HIT"
+	// CacheMiss means the products were scraped for the request.
+	CacheMiss = "MISS"
+	// CacheStale means the products were served from a cache entry past
+	// its TTL while they are scraped again in the background.
+	CacheStale = "STALE"
+)
+
+const (
+	// refreshTimeout bounds the background refresh of a stale entry.
+	refreshTimeout = 30 * time.Second
+	// anyAge accepts cached products of any age.
+	anyAge = time.Duration(math.MaxInt64)
+)
+
+// now returns the current time. Tests replace it to age cache entries.
+var now = time.Now
+
+// cachedProducts is the cache entry of a scrape.
+type cachedProducts struct {
+	Products  []internal.Product `json:"products"`
+	ScrapedAt time.Time          `json:"scraped_at"`
+}
+
+// cacheKey identifies the products scraped from site for query with opts.
+// The query is normalized, so spellings of a query share scrapes and cache
+// entries.
+func cacheKey(site, query string, opts scrapers.Options) string {
+	return fmt.Sprintf("%s-%s-%d-%d", site, normalizeQuery(query), opts.MaxPages, opts.Limit)
+}
+
+// parseMaxAge reads the optional 'max_age' parameter: cached products that
+// are at least max_age seconds old are not served, so max_age=0 always
+// scrapes.
+func parseMaxAge(c *gin.Context) (time.Duration, error) {
+	raw := c.Query("max_age")
+	if raw == "" {
+		return anyAge, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("'max_age' must be a non-negative integer")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// scrape returns the products for site and query along with their X-Cache
+// status. Products cached within the site's TTL are served as they are.
+// Past the TTL they are served stale while a background scrape refreshes
+// them, until the site's stale period ends as well. Products at least
+// maxAge old are never served from the cache.
+//
+// Concurrent scrapes of the same products are shared. The returned products
+// may be shared with other requests and must not be modified. Stores are
+// searched for the query as the user typed it, as only they know how to
+// fold its case.
+func (h *Handler) scrape(ctx context.Context, site, query string, opts scrapers.Options, maxAge time.Duration) ([]internal.Product, string, error) {
+	query = strings.TrimSpace(query)
+	policy := scrapers.SiteCachePolicy(site)
+
+	if entry, found := h.cached(ctx, cacheKey(site, query, opts)); found {
+		age := now().Sub(entry.ScrapedAt)
+		switch {
+		case age >= maxAge:
+		case age < policy.TTL():
+			return entry.Products, CacheHit, nil
+		case age < policy.TTL()+policy.Stale():
+			h.refresh(site, query, opts)
+			return entry.Products, CacheStale, nil
+		}
+	}
+
+	products, err := h.scrapeAndStore(ctx, site, query, opts)
+	return products, CacheMiss, err
+}
+
+// refresh scrapes the products for site and query again in the background.
+func (h *Handler) refresh(site, query string, opts scrapers.Options) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+
+		if _, err := h.scrapeAndStore(ctx, site, query, opts); err != nil {
+			log.Printf("Warning: refreshing the %s search for %q: %v", site, query, err)
+		}
+	}()
+}
+
+// scrapeAndStore scrapes the products for site and query and caches them, sharing the scrape with concurrent identical requests.
+func (h *Handler) scrapeAndStore(ctx context.Context, site, query string, opts scrapers.Options) ([]internal.Product, error) {
+	scraper, ok := scrapers.Lookup(site)
+	if !ok {
+		return nil, fmt.Errorf("invalid site: %s", site)
+	}
+
+	key := cacheKey(site, query, opts)
+	products, shared, err := h.scrapes.do(ctx, key, func(ctx context.Context) ([]internal.Product, error) {
+		products, err := scraper.Scrape(ctx, query, opts)
+		if err != nil {
+			return nil, err
+		}
+		h.store(ctx, key, site, products)
+		return products, nil
+	})
+	if shared {
+		log.Printf("INFO: %s search for %q shared an identical scrape in progress", site, query)
+	}
+	return products, err
+}
+
+// cached returns the cache entry stored under key. A failing cache is
+// treated as empty, so the products are scraped again.
+func (h *Handler) cached(ctx context.Context, key string) (cachedProducts, bool) {
+	var entry cachedProducts
+
+	data, found, err := h.Cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("Warning: reading %s from the cache: %v", key, err)
+		return entry, false
+	}
+	if !found {
+		return entry, false
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("Warning: decoding cached %s: %v", key, err)
+		return entry, false
+	}
+	return entry, true
+}
+
+// store caches the products scraped from site under key for as long as the
+// site's cache policy serves them. Failures are only logged, the products
+// are served either way.
+func (h *Handler) store(ctx context.Context, key, site string, products []internal.Product) {
+	policy := scrapers.SiteCachePolicy(site)
+	ttl := policy.TTL() + policy.Stale()
+	if ttl <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(cachedProducts{Products: products, ScrapedAt: now()})
+	if err == nil {
+		err = h.Cache.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		log.Printf("Warning: caching %s: %v", key, err)
+	}
+}
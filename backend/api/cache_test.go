@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"smartyshop/cache"
+	"smartyshop/internal"
+	"smartyshop/scrapers"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingScraper returns a single product named after the number of
+// scrapes it ran, and keeps the last query it was asked for.
+type countingScraper struct {
+	scrapes *int32
+	query   *atomic.Value
+}
+
+func (s countingScraper) Scrape(ctx context.Context, query string, opts scrapers.Options) ([]internal.Product, error) {
+	s.query.Store(query)
+	n := atomic.AddInt32(s.scrapes, 1)
+	return []internal.Product{{Title: "scrape " + strconv.Itoa(int(n))}}, nil
+}
+
+var (
+	testScrapes int32
+	testQuery   atomic.Value
+)
+
+func init() {
+	scrapers.Register(scrapers.Site{ID: "apitest", Name: "API Test", Domain: "www.apitest.com"},
+		func() scrapers.Scraper { return countingScraper{scrapes: &testScrapes, query: &testQuery} })
+}
+
+func TestScrapeServesStaleWhileRevalidating(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	c := cache.NewMemory(10, 0)
+	defer c.Close()
+	h := NewHandler(c)
+	ctx := context.Background()
+	policy := scrapers.SiteCachePolicy("apitest")
+	atomic.StoreInt32(&testScrapes, 0)
+
+	scrape := func(query string, maxAge time.Duration) (string, string) {
+		t.Helper()
+		products, status, err := h.scrape(ctx, "apitest", query, scrapers.Options{}, maxAge)
+		if err != nil || len(products) != 1 {
+			t.Fatalf("scrape = %v, %v", products, err)
+		}
+		return products[0].Title, status
+	}
+	check := func(name, query string, maxAge time.Duration, wantTitle, wantStatus string) {
+		t.Helper()
+		if title, status := scrape(query, maxAge); title != wantTitle || status != wantStatus {
+			t.Errorf("%s: got %q (%s), want %q (%s)", name, title, status, wantTitle, wantStatus)
+		}
+	}
+
+	check("first request", "iPhone 15", anyAge, "scrape 1", CacheMiss)
+	// Stores get the query as it was typed, not the cache key's spelling.
+	if query := testQuery.Load(); query != "iPhone 15" {
+		t.Errorf("scraped query = %q, want %q", query, "iPhone 15")
+	}
+	check("repeat request", "iPhone 15", anyAge, "scrape 1", CacheHit)
+	check("same query spelled differently", "  iphone   15 ", anyAge, "scrape 1", CacheHit)
+
+	// Past the TTL the entry is served once more while it is refreshed.
+	clock = clock.Add(policy.TTL())
+	check("expired entry", "iPhone 15", anyAge, "scrape 1", CacheStale)
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&testScrapes) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// The refresh stores its products after the scrape returns.
+	for time.Now().Before(deadline) {
+		if entry, _ := h.cached(ctx, cacheKey("apitest", "iphone 15", scrapers.Options{})); entry.Products[0].Title == "scrape 2" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	check("after the refresh", "iPhone 15", anyAge, "scrape 2", CacheHit)
+
+	// max_age forces a scrape when the entry is older.
+	clock = clock.Add(time.Minute)
+	check("max_age below the entry's age", "iPhone 15", 30*time.Second, "scrape 3", CacheMiss)
+	check("max_age=0", "iPhone 15", 0, "scrape 4", CacheMiss)
+
+	// Past the stale period the entry is not served at all.
+	clock = clock.Add(policy.TTL() + policy.Stale())
+	check("entry past its stale period", "iPhone 15", anyAge, "scrape 5", CacheMiss)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"smartyshop/internal"
+	"strings"
+	"sync"
+)
+
+// scrapeGroup coalesces identical scrapes that run at the same time: the
+// first caller for a key starts the scrape and later callers wait for its
+// result instead of scraping again.
+type scrapeGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a scrape in progress. It runs until the deadline of the caller
+// that started it, which later callers joining it inherit: a caller with a
+// longer deadline still gets the scrape's context.DeadlineExceeded error
+// when the first deadline passes.
+type flight struct {
+	done     chan struct{}
+	products []internal.Product
+	err      error
+
+	// waiters is the number of callers still waiting for the scrape, which
+	// is cancelled once all of them have given up.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do returns the result of scrape for key, sharing it with every concurrent
+// caller for the same key. The scrape runs on its own context, so a caller
+// that gives up does not fail it for the others; it is only cancelled once
+// every caller has given up. It keeps the deadline of the caller that
+// started it, see flight. Callers return ctx.Err() when their ctx is done
+// first. shared reports whether the result came from another caller's
+// scrape. The returned products are shared and must not be modified.
+func (g *scrapeGroup) do(ctx context.Context, key string, scrape func(ctx context.Context) ([]internal.Product, error)) (products []internal.Product, shared bool, err error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		var scrapeCtx context.Context
+		var cancel context.CancelFunc
+		if deadline, ok := ctx.Deadline(); ok {
+			scrapeCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			scrapeCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go func() {
+			defer cancel()
+			products, err := scrape(scrapeCtx)
+
+			g.mu.Lock()
+			g.forget(key, f)
+			g.mu.Unlock()
+
+			f.products, f.err = products, err
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.products, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Later callers start a new scrape instead of joining the
+			// cancelled one.
+			g.forget(key, f)
+			f.cancel()
+		}
+		g.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+// forget removes f from the scrapes in progress unless it was replaced
+// already. g.mu must be held.
+func (g *scrapeGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// normalizeQuery folds the spelling differences of a query that do not
+// change its results, so "iPhone  15" and "iphone 15" share scrapes and
+// cache entries.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"smartyshop/internal"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeGroupSharesConcurrentScrapes(t *testing.T) {
+	var g scrapeGroup
+	var scrapes int32
+	release := make(chan struct{})
+	scrape := func(ctx context.Context) ([]internal.Product, error) {
+		atomic.AddInt32(&scrapes, 1)
+		<-release
+		return []internal.Product{{Title: "iPhone 15"}}, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	var sharedCount int32
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			products, shared, err := g.do(context.Background(), "trendyol-iphone 15", scrape)
+			if err != nil || len(products) != 1 {
+				t.Errorf("do = %v, %v", products, err)
+			}
+			if shared {
+				atomic.AddInt32(&sharedCount, 1)
+			}
+		}()
+	}
+
+	// Wait until every caller joined the scrape before letting it finish.
+	for {
+		g.mu.Lock()
+		f := g.flights["trendyol-iphone 15"]
+		joined := f != nil && f.waiters == callers
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if scrapes != 1 {
+		t.Errorf("%d scrapes ran, want 1", scrapes)
+	}
+	if sharedCount != callers-1 {
+		t.Errorf("%d callers shared the scrape, want %d", sharedCount, callers-1)
+	}
+
+	// Once finished, the next caller scrapes again.
+	release = make(chan struct{})
+	close(release)
+	if _, shared, _ := g.do(context.Background(), "trendyol-iphone 15", scrape); shared || scrapes != 2 {
+		t.Errorf("finished scrape was shared, %d scrapes ran", scrapes)
+	}
+}
+
+func TestScrapeGroupCancelsOnlyWhenEveryCallerLeft(t *testing.T) {
+	var g scrapeGroup
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	release := make(chan struct{})
+	scrape := func(ctx context.Context) ([]internal.Product, error) {
+		close(started)
+		select {
+		case <-release:
+			return []internal.Product{{Title: "iPhone 15"}}, nil
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+	}
+
+	impatient, leave := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(impatient, "key", scrape)
+		errs <- err
+	}()
+	<-started
+
+	patient := make(chan []internal.Product, 1)
+	go func() {
+		products, _, _ := g.do(context.Background(), "key", scrape)
+		patient <- products
+	}()
+	for {
+		g.mu.Lock()
+		joined := g.flights["key"].waiters == 2
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	leave()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("caller that left got %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-cancelled:
+		t.Fatalf("scrape cancelled while a caller was still waiting")
+	default:
+	}
+
+	close(release)
+	if products := <-patient; len(products) != 1 {
+		t.Errorf("remaining caller got %v", products)
+	}
+}
+
+func TestScrapeGroupKeepsTheFirstCallersDeadline(t *testing.T) {
+	var g scrapeGroup
+	scrape := func(ctx context.Context) ([]internal.Product, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return []internal.Product{{Title: "no deadline"}}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	if products, _, err := g.do(context.Background(), "key", scrape); err != nil || len(products) != 1 {
+		t.Errorf("scrape without a deadline = %v, %v", products, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := g.do(ctx, "key", scrape); err != context.DeadlineExceeded {
+		t.Errorf("scrape past its deadline = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	if got := normalizeQuery("  iPhone   15 Pro "); got != "iphone 15 pro" {
+		t.Errorf("normalizeQuery = %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"smartyshop/scrapers"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enrichLimit is the maximum number of products whose detail pages are
+// fetched when a listing is requested with enrich=true.
+const enrichLimit = 10
+
+var errInvalidEnrich = errors.New("'enrich' must be true or false")
+
+// GetProductDetail handles the /products/detail endpoint.
+func (h *Handler) GetProductDetail(c *gin.Context) {
+	productURL := c.Query("url")
+	if productURL == "" {
+		c.JSON(400, gin.H{"error": "'url' parameter is required"})
+		return
+	}
+
+	site, ok := scrapers.LookupURL(productURL)
+	if !ok {
+		c.JSON(400, gin.H{"error": "url does not belong to a supported site"})
+		return
+	}
+
+	scraper, _ := scrapers.Lookup(site.ID)
+	detailScraper, ok := scraper.(scrapers.DetailScraper)
+	if !ok {
+		c.JSON(400, gin.H{"error": "product details are not supported for " + site.Name})
+		return
+	}
+
+	product, err := detailScraper.Detail(c.Request.Context(), productURL)
+	if err != nil {
+		writeScrapeError(c, scrapers.DetailBreaker(site.ID), err)
+		return
+	}
+
+	c.JSON(200, product)
+}
+
+// wantsEnrich reports whether the request asks for products to be enriched
+// with their detail pages via the optional 'enrich' parameter.
+func wantsEnrich(c *gin.Context) (bool, error) {
+	raw := c.Query("enrich")
+	if raw == "" {
+		return false, nil
+	}
+	enrich, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errInvalidEnrich
+	}
+	return enrich, nil
+}
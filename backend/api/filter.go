@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"smartyshop/internal"
+	"smartyshop/pkg/utils"
+	"smartyshop/ranking"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productFilter selects the products returned by the product endpoints.
+// Zero values do not filter.
+type productFilter struct {
+	// MinPrice and MaxPrice are in minor units, like Money.Amount.
+	MinPrice   int64
+	MaxPrice   int64
+	MinRating  float64
+	MinReviews int
+	// Include and Exclude are keywords folded by foldKeyword that the
+	// title must all contain, or none of.
+	Include []string
+	Exclude []string
+
+	// Relevance annotates the products with their relevance to the query,
+	// nil when the request has no query.
+	Relevance    *ranking.RelevanceScorer
+	MinRelevance float64
+}
+
+// parseFilter reads the optional 'min_price' and 'max_price' (in TL, e.g.
+// 20000 or 20.000), 'min_rating', 'min_reviews', 'include' and 'exclude'
+// (comma separated keywords), 'min_relevance' (0 to 1) and 'category' (a
+// hint for the relevance scorer) parameters.
+func parseFilter(c *gin.Context) (productFilter, error) {
+	var f productFilter
+
+	for _, p := range []struct {
+		name  string
+		value *int64
+	}{{"min_price", &f.MinPrice}, {"max_price", &f.MaxPrice}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		amount, ok := utils.ParseTurkishPrice(raw)
+		if !ok || strings.HasPrefix(strings.TrimSpace(raw), "-") {
+			return f, fmt.Errorf("'%s' must be a non-negative price", p.name)
+		}
+		*p.value = amount
+	}
+	if f.MaxPrice > 0 && f.MinPrice > f.MaxPrice {
+		return f, fmt.Errorf("'min_price' must not be above 'max_price'")
+	}
+
+	if raw := c.Query("min_rating"); raw != "" {
+		rating, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			return f, fmt.Errorf("'min_rating' must be a number between 0 and 5")
+		}
+		f.MinRating = rating
+	}
+
+	if raw := c.Query("min_reviews"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("'min_reviews' must be a non-negative integer")
+		}
+		f.MinReviews = n
+	}
+
+	if raw := c.Query("min_relevance"); raw != "" {
+		relevance, err := strconv.ParseFloat(raw, 64)
+		if err != nil || relevance < 0 || relevance > 1 {
+			return f, fmt.Errorf("'min_relevance' must be a number between 0 and 1")
+		}
+		f.MinRelevance = relevance
+	}
+
+	if query := c.Query("query"); query != "" {
+		scorer, err := ranking.NewRelevanceScorer(query, c.Query("category"))
+		if err != nil {
+			return f, fmt.Errorf("'category' must be one of %s", strings.Join(ranking.Categories(), ", "))
+		}
+		f.Relevance = scorer
+	}
+
+	f.Include = parseKeywords(c.Query("include"))
+	f.Exclude = parseKeywords(c.Query("exclude"))
+	return f, nil
+}
+
+// parseKeywords splits a comma separated list of keywords and folds them.
+func parseKeywords(raw string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(raw, ",") {
+		if keyword = foldKeyword(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// foldKeyword lowercases text and replaces its Turkish characters, so
+// "kılıf" matches "KILIF" and "kilif".
+func foldKeyword(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(utils.ConvertToEnglishChars(text))), " ")
+}
+
+// match reports whether p passes the filter. Products without a price or
+// rating fail the filters on them.
+func (f productFilter) match(p internal.Product) bool {
+	if f.MinPrice > 0 && (p.Price.IsZero() || p.Price.Amount < f.MinPrice) {
+		return false
+	}
+	if f.MaxPrice > 0 && (p.Price.IsZero() || p.Price.Amount > f.MaxPrice) {
+		return false
+	}
+	if f.MinRating > 0 && (!p.HasRating() || p.RatingValue() < f.MinRating) {
+		return false
+	}
+	if p.ReviewsCount < f.MinReviews {
+		return false
+	}
+	if f.MinRelevance > 0 && (p.Relevance == nil || *p.Relevance < f.MinRelevance) {
+		return false
+	}
+
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return true
+	}
+	title := foldKeyword(p.Title)
+	for _, keyword := range f.Include {
+		if !strings.Contains(title, keyword) {
+			return false
+		}
+	}
+	for _, keyword := range f.Exclude {
+		if strings.Contains(title, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply annotates the products with their relevance and returns the ones
+// passing the filter in a new slice, products may be shared with the cache
+// and other requests.
+func (f productFilter) apply(products []internal.Product) []internal.Product {
+	if f.Relevance != nil {
+		products = f.Relevance.Annotate(products)
+	}
+
+	filtered := []internal.Product{}
+	for _, p := range products {
+		if f.match(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
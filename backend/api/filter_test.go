@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"smartyshop/internal"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProductFilter(t *testing.T) {
+	priced := func(title string, rating float64, reviews int, price float64) internal.Product {
+		p := rated(title, rating, reviews, 0)
+		if price > 0 {
+			p.Price = internal.NewMoney(price, internal.CurrencyTRY)
+		}
+		return p
+	}
+	products := []internal.Product{
+		priced("Apple iPhone 15 128 GB", 4.7, 12000, 49999),
+		priced("Apple iPhone 15 Silikon Kılıf", 4.5, 800, 299),
+		priced("Samsung Galaxy A55", 4.4, 150, 18999),
+		priced("Xiaomi Redmi Note 13", 0, 0, 9999),
+		priced("Samsung Galaxy A15", 3.9, 2000, 0),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Apple iPhone 15 128 GB", "Apple iPhone 15 Silikon Kılıf", "Samsung Galaxy A55", "Xiaomi Redmi Note 13", "Samsung Galaxy A15"}},
+		// Products without a price or rating fail the filters on them.
+		{"max_price=20.000&min_rating=4&min_reviews=100", []string{"Apple iPhone 15 Silikon Kılıf", "Samsung Galaxy A55"}},
+		{"min_price=1000", []string{"Apple iPhone 15 128 GB", "Samsung Galaxy A55", "Xiaomi Redmi Note 13"}},
+		{"include=iphone,15&exclude=KILIF", []string{"Apple iPhone 15 128 GB"}},
+		{"include=galaxy+a", []string{"Samsung Galaxy A55", "Samsung Galaxy A15"}},
+		{"max_price=20000&sort=price", []string{"Apple iPhone 15 Silikon Kılıf", "Xiaomi Redmi Note 13", "Samsung Galaxy A55"}},
+		// The case and products unrelated to the query are dropped.
+		{"query=iphone+15&min_relevance=0.5", []string{"Apple iPhone 15 128 GB"}},
+		{"query=iphone+15&min_relevance=0.5&category=accessory", []string{"Apple iPhone 15 128 GB", "Apple iPhone 15 Silikon Kılıf"}},
+		// Phones are listed by brand and model, without the category's words.
+		{"query=iphone+15&min_relevance=0.6&category=phone", []string{"Apple iPhone 15 128 GB"}},
+		{"query=samsung+galaxy&strategy=relevance", []string{"Samsung Galaxy A55", "Samsung Galaxy A15", "Apple iPhone 15 128 GB", "Apple iPhone 15 Silikon Kılıf", "Xiaomi Redmi Note 13"}},
+		{"min_reviews=100&sort=reviews&order=asc", []string{"Samsung Galaxy A55", "Apple iPhone 15 Silikon Kılıf", "Samsung Galaxy A15", "Apple iPhone 15 128 GB"}},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/products?"+tt.query, nil)
+
+		f, err := parseFilter(c)
+		if err != nil {
+			t.Fatalf("%s: parseFilter: %v", tt.query, err)
+		}
+		opts, err := parseSortOptions(c, "")
+		if err != nil {
+			t.Fatalf("%s: parseSortOptions: %v", tt.query, err)
+		}
+		if got := titles(sortProducts(f.apply(products), "", opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterRejectsInvalidValues(t *testing.T) {
+	for _, query := range []string{"min_price=abc", "max_price=-5", "min_price=200&max_price=100", "min_rating=6", "min_reviews=-1", "min_relevance=2", "query=iphone&category=car"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/products?"+query, nil)
+		if _, err := parseFilter(c); err == nil {
+			t.Errorf("%s was accepted", query)
+		}
+	}
+}
@@ -2,35 +2,37 @@ package api
 
 import (
 	"fmt"
+	"math"
+	"smartyshop/cache"
 	"smartyshop/config"
 	"smartyshop/gemini"
 	"smartyshop/internal"
 	"smartyshop/scrapers"
-	"sort"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// cacheEntry stores products and an expiration time.
-type cacheEntry struct {
-	products   []internal.Product
-	expiration time.Time
-}
+// defaultGeminiSite is scraped by /gemini/query when the request carries
+// neither products nor a site.
+const defaultGeminiSite = "trendyol"
+
+// geminiProductLimit is the number of best ranked products sent to Gemini,
+// keeping the prompt short.
+const geminiProductLimit = 60
 
 // Handler holds the cache and other dependencies.
 type Handler struct {
-	Cache      map[string]cacheEntry
-	CacheMutex *sync.Mutex
+	Cache cache.Cache
+
+	// scrapes coalesces identical concurrent scrapes.
+	scrapes scrapeGroup
 }
 
-// NewHandler creates a new handler with an initialized cache.
-func NewHandler() *Handler {
-	return &Handler{
-		Cache:      make(map[string]cacheEntry),
-		CacheMutex: &sync.Mutex{},
-	}
+// NewHandler creates a new handler storing scraped products in c.
+func NewHandler(c cache.Cache) *Handler {
+	return &Handler{Cache: c}
 }
 
 // GetProducts handles the /products endpoint.
@@ -43,87 +45,146 @@ func (h *Handler) GetProducts(c *gin.Context) {
 		return
 	}
 
-	cacheKey := fmt.Sprintf("%s-%s", site, query)
+	if _, ok := scrapers.LookupSite(site); !ok {
+		c.JSON(400, gin.H{"error": "invalid site"})
+		return
+	}
 
-	h.CacheMutex.Lock()
-	entry, found := h.Cache[cacheKey]
-	h.CacheMutex.Unlock()
+	opts, err := parseOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
-	if found && time.Now().Before(entry.expiration) {
-		c.JSON(200, entry.products)
+	enrich, err := wantsEnrich(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	var scraper scrapers.Scraper
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
-	switch site {
-	case "trendyol":
-		scraper = &scrapers.TrendyolScraper{}
-	case "teknosa":
-		scraper = &scrapers.TeknosaScraper{}
-	case "mediamarkt":
-		scraper = &scrapers.MediaMarktScraper{}
-	case "amazon":
-		scraper = &scrapers.AmazonScraper{}
-	default:
-		c.JSON(400, gin.H{"error": "invalid site"})
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	products, err := scraper.Scrape(query)
+	sortOpts, err := parseSortOptions(c, "")
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	h.CacheMutex.Lock()
-	h.Cache[cacheKey] = cacheEntry{
-		products:   products,
-		expiration: time.Now().Add(10 * time.Second),
+	products, cacheStatus, err := h.scrape(c.Request.Context(), site, query, opts, maxAge)
+	if err != nil {
+		writeScrapeError(c, scrapers.Breaker(site), err)
+		return
+	}
+	c.Header("X-Cache", cacheStatus)
+	products = sortProducts(filter.apply(products), query, sortOpts)
+
+	// Enrich returns a copy, the cached products stay as scraped. Only the
+	// products passing the filter are enriched.
+	if enrich {
+		products = scrapers.Enrich(c.Request.Context(), products, enrichLimit)
 	}
-	h.CacheMutex.Unlock()
 
 	c.JSON(200, products)
 }
 
-// GetTop10Products handles the /products/top10 endpoint.
-func (h *Handler) GetTop10Products(c *gin.Context) {
-	site := c.Query("site")
-	query := c.Query("query")
+// scrapeErrorStatus maps the kinds of scrape errors to the status codes
+// they are answered with. Other errors are answered with 500.
+var scrapeErrorStatus = map[string]int{
+	// The store refused the request.
+	scrapers.ErrorKindBlocked: 403,
+	// The store wants a human, clients should back off.
+	scrapers.ErrorKindCaptcha: 429,
+	// The store answered with something the scraper cannot read.
+	scrapers.ErrorKindLayoutChanged:      502,
+	scrapers.ErrorKindConsentWall:        502,
+	scrapers.ErrorKindUnexpectedRedirect: 502,
+	scrapers.ErrorKindCircuitOpen:        503,
+	scrapers.ErrorKindNoHealthyProxy:     503,
+	scrapers.ErrorKindTimeout:            504,
+}
 
-	if site == "" || query == "" {
-		c.JSON(400, gin.H{"error": "'site' and 'query' parameters are required"})
-		return
+// writeScrapeError responds with the error of a failed scrape, with a status
+// code and error_kind telling why it failed. Scrapes rejected by breaker, the
+// circuit breaker that guarded them, are reported as temporarily unavailable.
+func writeScrapeError(c *gin.Context, breaker scrapers.BreakerState, err error) {
+	kind := scrapers.ErrorKind(err)
+	status, ok := scrapeErrorStatus[kind]
+	if !ok {
+		status = 500
 	}
 
-	cacheKey := fmt.Sprintf("%s-%s", site, query)
+	if kind == scrapers.ErrorKindCircuitOpen {
+		if retryAt := breaker.RetryAt; retryAt != nil {
+			seconds := int(math.Ceil(time.Until(*retryAt).Seconds()))
+			if seconds > 0 {
+				c.Header("Retry-After", strconv.Itoa(seconds))
+			}
+		}
+	}
+	c.JSON(status, gin.H{"error": err.Error(), "error_kind": kind})
+}
 
-	h.CacheMutex.Lock()
-	entry, found := h.Cache[cacheKey]
-	h.CacheMutex.Unlock()
+// parseOptions reads the optional 'page' (number of result pages to follow
+// per site) and 'limit' (maximum number of products per site) parameters.
+func parseOptions(c *gin.Context) (scrapers.Options, error) {
+	var opts scrapers.Options
 
-	if !found || time.Now().After(entry.expiration) {
-		c.JSON(400, gin.H{"error": "products not cached, please fetch them first with /products"})
-		return
+	if page := c.Query("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return opts, fmt.Errorf("'page' must be a positive integer")
+		}
+		opts.MaxPages = n
 	}
 
-	sortedProducts := entry.products
-	sort.Slice(sortedProducts, func(i, j int) bool {
-		return sortedProducts[i].Rating > sortedProducts[j].Rating
-	})
-
-	if len(sortedProducts) > 10 {
-		sortedProducts = sortedProducts[:10]
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return opts, fmt.Errorf("'limit' must be a positive integer")
+		}
+		opts.Limit = n
 	}
 
-	c.JSON(200, sortedProducts)
+	return opts, nil
+}
+
+// GetSites handles the /sites endpoint.
+func (h *Handler) GetSites(c *gin.Context) {
+	c.JSON(200, scrapers.Sites())
+}
+
+// GetSiteHealth handles the /health/sites endpoint.
+func (h *Handler) GetSiteHealth(c *gin.Context) {
+	c.JSON(200, scrapers.HealthReport())
+}
+
+// GetProxyHealth handles the /health/proxies endpoint.
+func (h *Handler) GetProxyHealth(c *gin.Context) {
+	c.JSON(200, scrapers.ProxyReport())
 }
 
 // GeminiQuery handles the /gemini/query endpoint.
 func (h *Handler) GeminiQuery(c *gin.Context) {
 	type GeminiQueryRequest struct {
 		Query    string             `json:"query"`
+		Site     string             `json:"site"`
 		Products []internal.Product `json:"products"`
+		// Enrich fetches the detail pages of the products before they are
+		// analyzed, giving Gemini brand, seller, stock and specifications.
+		Enrich bool `json:"enrich"`
+		// Strategy ranks the products to pick the ones sent to Gemini,
+		// the default strategy when empty.
+		Strategy string `json:"strategy"`
 	}
 
 	var req GeminiQueryRequest
@@ -132,6 +193,12 @@ func (h *Handler) GeminiQuery(c *gin.Context) {
 		return
 	}
 
+	strategy, err := parseStrategy(req.Strategy)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	apiKey := config.GetGeminiAPIKey()
 	if apiKey == "" {
 		c.JSON(500, gin.H{"error": "GEMINI_API_KEY not set"})
@@ -141,11 +208,18 @@ func (h *Handler) GeminiQuery(c *gin.Context) {
 	// If no products are provided, try to scrape them based on the query
 	productsToAnalyze := req.Products
 	if len(productsToAnalyze) == 0 {
-		var scraper scrapers.Scraper
-		// Default to Trendyol for scraping if no site is specified or products are empty
-		scraper = &scrapers.TrendyolScraper{} 
-		
-		scrapedProducts, err := scraper.Scrape(req.Query)
+		site := req.Site
+		if site == "" {
+			site = defaultGeminiSite
+		}
+
+		scraper, ok := scrapers.Lookup(site)
+		if !ok {
+			c.JSON(400, gin.H{"error": "invalid site"})
+			return
+		}
+
+		scrapedProducts, err := scraper.Scrape(c.Request.Context(), req.Query, scrapers.Options{})
 		if err != nil {
 			// Log the error but don't fail the request, proceed with empty products
 			fmt.Printf("Error scraping products: %v\n", err)
@@ -154,6 +228,16 @@ func (h *Handler) GeminiQuery(c *gin.Context) {
 		}
 	}
 
+	// Only the best products are sent, so they are also the ones enriched.
+	productsToAnalyze = strategy.Rank(productsToAnalyze, req.Query)
+	if len(productsToAnalyze) > geminiProductLimit {
+		productsToAnalyze = productsToAnalyze[:geminiProductLimit]
+	}
+
+	if req.Enrich {
+		productsToAnalyze = scrapers.Enrich(c.Request.Context(), productsToAnalyze, enrichLimit)
+	}
+
 	resp, err := gemini.GetGeminiProductInsights(productsToAnalyze, req.Query, apiKey)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
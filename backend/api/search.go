@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smartyshop/internal"
+	"smartyshop/scrapers"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// siteTimeout bounds how long a single site may take during a multi-site search.
+const siteTimeout = 15 * time.Second
+
+// Per-site statuses reported by the /search endpoint.
+const (
+	SiteStatusOK       = "ok"
+	SiteStatusEmpty    = "empty"
+	SiteStatusError    = "error"
+	SiteStatusTimedOut = "timed_out"
+	// SiteStatusUnavailable marks a site skipped because its circuit
+	// breaker is open after repeated failures.
+	SiteStatusUnavailable = "unavailable"
+)
+
+// SiteResult describes the outcome of scraping one site during a search.
+type SiteResult struct {
+	Site      string `json:"site"`
+	Status    string `json:"status"`
+	Count     int    `json:"count"`
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+	// Cache is the X-Cache status of the site's products.
+	Cache      string `json:"cache,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SearchResponse is the body returned by the /search endpoint.
+type SearchResponse struct {
+	Query    string             `json:"query"`
+	Products []internal.Product `json:"products"`
+	Sites    []SiteResult       `json:"sites"`
+}
+
+// Search handles the /search endpoint. It scrapes every selected site
+// concurrently and merges the results into a single response.
+func (h *Handler) Search(c *gin.Context) {
+	query := c.Query("query")
+	if query == "" {
+		c.JSON(400, gin.H{"error": "'query' parameter is required"})
+		return
+	}
+
+	sites, err := parseSites(c.Query("sites"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := parseOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	sortOpts, err := parseSortOptions(c, "")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := h.searchSites(c.Request.Context(), sites, query, opts, maxAge)
+	resp.Products = sortProducts(filter.apply(resp.Products), query, sortOpts)
+	c.JSON(200, resp)
+}
+
+// parseSites splits a comma separated list of site identifiers and validates
+// each of them. An empty list selects every registered site.
+func parseSites(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return scrapers.SiteIDs(), nil
+	}
+
+	var sites []string
+	seen := make(map[string]bool)
+	for _, site := range strings.Split(raw, ",") {
+		site = strings.ToLower(strings.TrimSpace(site))
+		if site == "" || seen[site] {
+			continue
+		}
+		if _, ok := scrapers.LookupSite(site); !ok {
+			return nil, fmt.Errorf("invalid site: %s", site)
+		}
+		seen[site] = true
+		sites = append(sites, site)
+	}
+	if len(sites) == 0 {
+		return scrapers.SiteIDs(), nil
+	}
+	return sites, nil
+}
+
+// searchSites runs the scrapers for all sites concurrently, each bounded by
+// siteTimeout, and merges their products in the order the sites were given.
+func (h *Handler) searchSites(ctx context.Context, sites []string, query string, opts scrapers.Options, maxAge time.Duration) SearchResponse {
+	results := make([]SiteResult, len(sites))
+	products := make([][]internal.Product, len(sites))
+
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(i int, site string) {
+			defer wg.Done()
+
+			siteCtx, cancel := context.WithTimeout(ctx, siteTimeout)
+			defer cancel()
+
+			start := time.Now()
+			p, cacheStatus, err := h.scrape(siteCtx, site, query, opts, maxAge)
+
+			result := SiteResult{Site: site, ErrorKind: scrapers.ErrorKind(err)}
+			if err == nil {
+				result.Cache = cacheStatus
+			}
+			switch {
+			case errors.Is(err, scrapers.ErrCircuitOpen):
+				result.Status = SiteStatusUnavailable
+				result.Error = err.Error()
+			case errors.Is(err, context.DeadlineExceeded):
+				result.Status = SiteStatusTimedOut
+				result.Error = "site did not respond within " + siteTimeout.String()
+			case err != nil:
+				result.Status = SiteStatusError
+				result.Error = err.Error()
+			case len(p) == 0:
+				result.Status = SiteStatusEmpty
+			default:
+				result.Status = SiteStatusOK
+				result.Count = len(p)
+				products[i] = p
+			}
+			result.DurationMs = time.Since(start).Milliseconds()
+			results[i] = result
+		}(i, site)
+	}
+	wg.Wait()
+
+	merged := []internal.Product{}
+	for _, p := range products {
+		merged = append(merged, p...)
+	}
+
+	return SearchResponse{
+		Query:    query,
+		Products: merged,
+		Sites:    results,
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"smartyshop/internal"
+	"smartyshop/ranking"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sort keys accepted by the 'sort' parameter.
+const (
+	// SortScore ranks by the score of the ranking strategy.
+	SortScore    = "score"
+	SortRating   = "rating"
+	SortReviews  = "reviews"
+	SortPrice    = "price"
+	SortDiscount = "discount"
+)
+
+// Sort orders accepted by the 'order' parameter.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// productLess orders two scored products ascending by a sort key. Products
+// missing the value, e.g. without a rating or a price, are handled by
+// productMissing.
+var productLess = map[string]func(a, b ranking.Scored) bool{
+	SortScore: func(a, b ranking.Scored) bool {
+		return a.Score < b.Score
+	},
+	SortRating: func(a, b ranking.Scored) bool {
+		if a.Product.RatingValue() != b.Product.RatingValue() {
+			return a.Product.RatingValue() < b.Product.RatingValue()
+		}
+		// Between equal ratings the one backed by more reviews ranks higher.
+		return a.Product.ReviewsCount < b.Product.ReviewsCount
+	},
+	SortReviews: func(a, b ranking.Scored) bool {
+		return a.Product.ReviewsCount < b.Product.ReviewsCount
+	},
+	SortPrice: func(a, b ranking.Scored) bool {
+		return a.Product.Price.Amount < b.Product.Price.Amount
+	},
+	SortDiscount: func(a, b ranking.Scored) bool {
+		return a.Product.DiscountPercent < b.Product.DiscountPercent
+	},
+}
+
+// productMissing reports whether a product lacks the value of a sort key.
+// Such products are ranked after every other product in either order.
+var productMissing = map[string]func(p ranking.Scored) bool{
+	SortScore:  func(p ranking.Scored) bool { return !p.Ok },
+	SortRating: func(p ranking.Scored) bool { return !p.Product.HasRating() },
+	SortPrice:  func(p ranking.Scored) bool { return p.Product.Price.IsZero() },
+}
+
+// defaultOrder is the order of a sort key when 'order' is omitted: the best
+// products first, i.e. the cheapest ones when sorting by price.
+var defaultOrder = map[string]string{
+	SortScore:    OrderDesc,
+	SortRating:   OrderDesc,
+	SortReviews:  OrderDesc,
+	SortPrice:    OrderAsc,
+	SortDiscount: OrderDesc,
+}
+
+// sortOptions select how products are sorted. An empty Sort keeps the
+// order the stores returned them in.
+type sortOptions struct {
+	Sort     string
+	Order    string
+	Strategy ranking.Strategy
+}
+
+// parseSortOptions reads the optional 'sort' (score, rating, reviews, price
+// or discount), 'order' (asc or desc, defaulting to the best products
+// first) and 'strategy' (the ranking strategy computing the score)
+// parameters. Without 'sort' products are sorted by defaultSort, or by
+// score when only a strategy is given.
+func parseSortOptions(c *gin.Context, defaultSort string) (sortOptions, error) {
+	opts := sortOptions{Sort: defaultSort}
+
+	strategy, err := parseStrategy(c.Query("strategy"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Strategy = strategy
+	if c.Query("strategy") != "" {
+		opts.Sort = SortScore
+	}
+
+	if key := strings.ToLower(c.Query("sort")); key != "" {
+		if _, ok := productLess[key]; !ok {
+			return opts, fmt.Errorf("'sort' must be one of %s, %s, %s, %s or %s", SortScore, SortRating, SortReviews, SortPrice, SortDiscount)
+		}
+		opts.Sort = key
+	}
+
+	opts.Order = defaultOrder[opts.Sort]
+	if order := strings.ToLower(c.Query("order")); order != "" {
+		if order != OrderAsc && order != OrderDesc {
+			return opts, fmt.Errorf("'order' must be %s or %s", OrderAsc, OrderDesc)
+		}
+		opts.Order = order
+	}
+
+	return opts, nil
+}
+
+// parseStrategy returns the ranking strategy called name, or the default
+// strategy if name is empty.
+func parseStrategy(name string) (ranking.Strategy, error) {
+	strategy, ok := ranking.Lookup(name)
+	if !ok {
+		var names []string
+		for _, s := range ranking.Strategies() {
+			names = append(names, s.Name)
+		}
+		return strategy, fmt.Errorf("'strategy' must be one of %s", strings.Join(names, ", "))
+	}
+	return strategy, nil
+}
+
+// sortProducts returns the products for query sorted by opts. It sorts a
+// copy, products may be shared with the cache and other requests.
+func sortProducts(products []internal.Product, query string, opts sortOptions) []internal.Product {
+	if opts.Sort == "" {
+		return products
+	}
+
+	scored := opts.Strategy.Score(products, query)
+	less := productLess[opts.Sort]
+	missing := productMissing[opts.Sort]
+	sort.SliceStable(scored, func(i, j int) bool {
+		a, b := scored[i], scored[j]
+		if missing != nil && missing(a) != missing(b) {
+			return !missing(a)
+		}
+		if opts.Order == OrderAsc {
+			return less(a, b)
+		}
+		return less(b, a)
+	})
+
+	sorted := make([]internal.Product, len(scored))
+	for i, s := range scored {
+		sorted[i] = s.Product
+	}
+	return sorted
+}
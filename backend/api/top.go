@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"smartyshop/internal"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultTopN is the number of products returned by /products/top when
+	// 'n' is omitted.
+	defaultTopN = 10
+	// maxTopN caps the 'n' parameter.
+	maxTopN = 100
+)
+
+// topOptions are the parameters of /products/top.
+type topOptions struct {
+	N int
+	sortOptions
+}
+
+// parseTopOptions reads the optional 'n' (number of products, default 10)
+// parameter along with the sort parameters, which sort by score unless
+// told otherwise.
+func parseTopOptions(c *gin.Context) (topOptions, error) {
+	opts := topOptions{N: defaultTopN}
+
+	if n := c.Query("n"); n != "" {
+		v, err := strconv.Atoi(n)
+		if err != nil || v < 1 || v > maxTopN {
+			return opts, fmt.Errorf("'n' must be an integer between 1 and %d", maxTopN)
+		}
+		opts.N = v
+	}
+
+	var err error
+	opts.sortOptions, err = parseSortOptions(c, SortScore)
+	return opts, err
+}
+
+// topProducts returns the first opts.N products for query ranked by opts.
+func topProducts(products []internal.Product, query string, opts topOptions) []internal.Product {
+	ranked := sortProducts(products, query, opts.sortOptions)
+	if len(ranked) > opts.N {
+		ranked = ranked[:opts.N]
+	}
+	return ranked
+}
+
+// GetTopProducts handles the /products/top endpoint. It scrapes the selected
+// sites like /search, reading them from the cache when possible, and returns
+// the best ranked products across all of them along with the per-site
+// statuses.
+func (h *Handler) GetTopProducts(c *gin.Context) {
+	if resp, ok := h.searchTop(c); ok {
+		c.JSON(200, resp)
+	}
+}
+
+// GetTop10Products handles the /products/top10 endpoint kept for older
+// clients, which returns the products of /products/top as a bare array.
+func (h *Handler) GetTop10Products(c *gin.Context) {
+	if resp, ok := h.searchTop(c); ok {
+		c.JSON(200, resp.Products)
+	}
+}
+
+// searchTop searches the sites selected by the request and ranks their
+// products for the top products endpoints. Invalid parameters are answered
+// with 400 and reported by ok being false.
+func (h *Handler) searchTop(c *gin.Context) (resp SearchResponse, ok bool) {
+	query := c.Query("query")
+	if query == "" {
+		c.JSON(400, gin.H{"error": "'query' parameter is required"})
+		return resp, false
+	}
+
+	// 'site' selects a single site and is kept for older clients.
+	rawSites := c.Query("sites")
+	if rawSites == "" {
+		rawSites = c.Query("site")
+	}
+	sites, err := parseSites(rawSites)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return resp, false
+	}
+
+	opts, err := parseOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return resp, false
+	}
+
+	top, err := parseTopOptions(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return resp, false
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return resp, false
+	}
+
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return resp, false
+	}
+
+	resp = h.searchSites(c.Request.Context(), sites, query, opts, maxAge)
+	resp.Products = topProducts(filter.apply(resp.Products), query, top)
+	return resp, true
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"smartyshop/cache"
+	"smartyshop/internal"
+	"smartyshop/ranking"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func rated(title string, rating float64, reviews int, price int64) internal.Product {
+	p := internal.Product{Title: title, ReviewsCount: reviews, Price: internal.Money{Amount: price}}
+	p.SetScrapedRating(rating)
+	return p
+}
+
+func titles(products []internal.Product) []string {
+	var t []string
+	for _, p := range products {
+		t = append(t, p.Title)
+	}
+	return t
+}
+
+func TestTopProducts(t *testing.T) {
+	products := []internal.Product{
+		rated("unrated", 0, 0, 100),
+		rated("good", 4.5, 100, 300),
+		rated("five stars, one review", 5, 1, 0),
+		rated("popular", 4.7, 12000, 200),
+		rated("meh", 3.5, 40, 400),
+	}
+	bayesian, _ := ranking.Lookup(ranking.Bayesian)
+
+	tests := []struct {
+		opts topOptions
+		want []string
+	}{
+		// A single review barely moves the Bayesian average of a product.
+		{topOptions{N: 10, sortOptions: sortOptions{Sort: SortScore, Order: OrderDesc}}, []string{"popular", "good", "five stars, one review", "meh", "unrated"}},
+		{topOptions{N: 10, sortOptions: sortOptions{Sort: SortRating, Order: OrderDesc}}, []string{"five stars, one review", "popular", "good", "meh", "unrated"}},
+		{topOptions{N: 2, sortOptions: sortOptions{Sort: SortRating, Order: OrderDesc}}, []string{"five stars, one review", "popular"}},
+		// Products without the value stay last in either order.
+		{topOptions{N: 10, sortOptions: sortOptions{Sort: SortRating, Order: OrderAsc}}, []string{"meh", "good", "popular", "five stars, one review", "unrated"}},
+		{topOptions{N: 10, sortOptions: sortOptions{Sort: SortPrice, Order: OrderAsc}}, []string{"unrated", "popular", "good", "meh", "five stars, one review"}},
+		{topOptions{N: 10, sortOptions: sortOptions{Sort: SortReviews, Order: OrderDesc}}, []string{"popular", "good", "meh", "five stars, one review", "unrated"}},
+	}
+	for _, tt := range tests {
+		tt.opts.Strategy = bayesian
+		if got := titles(topProducts(products, "phone", tt.opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("topProducts(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+
+	// The products are ranked on a copy.
+	if products[0].Title != "unrated" || products[2].Title != "five stars, one review" {
+		t.Errorf("topProducts reordered its input: %q", titles(products))
+	}
+}
+
+func TestParseTopOptions(t *testing.T) {
+	parse := func(query string) (topOptions, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/products/top?"+query, nil)
+		return parseTopOptions(c)
+	}
+
+	if opts, err := parse(""); err != nil || opts.N != 10 || opts.Sort != SortScore || opts.Order != OrderDesc || opts.Strategy.Name != ranking.Default {
+		t.Errorf("defaults = %+v, %v", opts, err)
+	}
+	if opts, err := parse("sort=price&n=5&strategy=wilson"); err != nil || opts.N != 5 || opts.Sort != SortPrice || opts.Order != OrderAsc || opts.Strategy.Name != ranking.Wilson {
+		t.Errorf("sort=price = %+v, %v", opts, err)
+	}
+	if opts, err := parse("sort=price&order=desc"); err != nil || opts.Order != OrderDesc {
+		t.Errorf("order=desc = %+v, %v", opts, err)
+	}
+	for _, query := range []string{"n=0", "n=101", "sort=name", "order=up", "strategy=random"} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("%s was accepted", query)
+		}
+	}
+}
+
+// TestTop10ProductsReturnsAnArray checks that the old /products/top10
+// address keeps answering with a bare array of products.
+func TestTop10ProductsReturnsAnArray(t *testing.T) {
+	c := cache.NewMemory(10, 0)
+	defer c.Close()
+	h := NewHandler(c)
+
+	get := func(handle gin.HandlerFunc, path string) []byte {
+		t.Helper()
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", path+"?site=apitest&query=iphone+15", nil)
+		handle(ctx)
+		if w.Code != 200 {
+			t.Fatalf("%s = %d %s", path, w.Code, w.Body)
+		}
+		return w.Body.Bytes()
+	}
+
+	var resp SearchResponse
+	if body := get(h.GetTopProducts, "/products/top"); json.Unmarshal(body, &resp) != nil || len(resp.Products) != 1 {
+		t.Errorf("/products/top = %s", body)
+	}
+	var products []internal.Product
+	if body := get(h.GetTop10Products, "/products/top10"); json.Unmarshal(body, &products) != nil || len(products) != 1 {
+		t.Errorf("/products/top10 = %s", body)
+	}
+}
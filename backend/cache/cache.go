@@ -0,0 +1,60 @@
+// Package cache stores scrape results for the API handlers. Values are
+// opaque byte slices so they can be kept in process or shared between
+// replicas through Redis.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backends supported by Open.
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Cache stores values under string keys for a limited time.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key. ok is false if there is none
+	// or it has expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the value stored under key, if any.
+	Delete(ctx context.Context, key string) error
+	// Close releases the resources of the cache.
+	Close() error
+}
+
+// Options selects and configures the cache backend returned by Open.
+type Options struct {
+	// Backend is BackendMemory (the default) or BackendRedis.
+	Backend string
+	// MaxEntries bounds the number of entries of the memory backend.
+	MaxEntries int
+	// MaxTTL caps the lifetime of the entries of the memory backend. Zero
+	// leaves it uncapped.
+	MaxTTL time.Duration
+	// RedisURL is the address of the Redis server of the redis backend,
+	// e.g. "redis://:password@localhost:6379/0".
+	RedisURL string
+}
+
+// Open returns the cache backend selected by opts.
+func Open(opts Options) (Cache, error) {
+	switch opts.Backend {
+	case "", BackendMemory:
+		return NewMemory(opts.MaxEntries, opts.MaxTTL), nil
+	case BackendRedis:
+		if opts.RedisURL == "" {
+			return nil, fmt.Errorf("a Redis URL is required by the %s cache", BackendRedis)
+		}
+		return NewRedis(opts.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", opts.Backend)
+	}
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2, 0)
+	defer m.Close()
+
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), time.Minute)
+	// Reading a makes b the least recently used entry.
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Fatalf("a missing")
+	}
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Errorf("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := m.Get(ctx, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len = %d, want 2", m.Len())
+	}
+}
+
+func TestMemoryExpiresEntries(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	ctx := context.Background()
+	m := NewMemory(10, time.Minute)
+	defer m.Close()
+
+	m.Set(ctx, "short", []byte("1"), 10*time.Second)
+	m.Set(ctx, "capped", []byte("2"), time.Hour)
+	m.Set(ctx, "unread", []byte("3"), 10*time.Second)
+
+	clock = clock.Add(10 * time.Second)
+	if _, ok, _ := m.Get(ctx, "short"); ok {
+		t.Errorf("entry served after its TTL")
+	}
+	if _, ok, _ := m.Get(ctx, "capped"); !ok {
+		t.Errorf("entry expired before its TTL")
+	}
+
+	m.sweep()
+	if m.Len() != 1 {
+		t.Errorf("Len after sweep = %d, want 1", m.Len())
+	}
+
+	// The TTL of capped was cut to the cache's maximum.
+	clock = clock.Add(50 * time.Second)
+	if _, ok, _ := m.Get(ctx, "capped"); ok {
+		t.Errorf("entry outlived the maximum TTL")
+	}
+}
+
+// respStandIn is a minimal in-process server speaking the Redis protocol.
+// AUTH without a username authenticates the "default" user.
+type respStandIn struct {
+	user     string
+	password string
+
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]string
+}
+
+// startRESPStandIn serves a respStandIn on a local port until the test
+// finishes and returns its address.
+func startRESPStandIn(t *testing.T, user, password string) (*respStandIn, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &respStandIn{user: user, password: password, values: make(map[string]string), ttls: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv, ln.Addr().String()
+}
+
+func (s *respStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		var reply string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			user, password := "default", args[1]
+			if len(args) == 3 {
+				user, password = args[1], args[2]
+			}
+			authed = user == s.user && password == s.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			reply = "+PONG\r\n"
+		case cmd == "SELECT":
+			reply = "+OK\r\n"
+		case cmd == "GET":
+			if v, ok := s.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case cmd == "SET":
+			s.values[args[1]] = args[2]
+			s.ttls[args[1]] = args[4]
+			reply = "+OK\r\n"
+		case cmd == "DEL":
+			delete(s.values, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisAgainstRESPStandIn(t *testing.T) {
+	srv, addr := startRESPStandIn(t, "default", "secret")
+	ctx := context.Background()
+
+	if _, err := NewRedis("redis://:wrong@" + addr); err == nil {
+		t.Fatalf("NewRedis accepted a wrong password")
+	}
+
+	c, err := Open(Options{Backend: BackendRedis, RedisURL: "redis://:secret@" + addr + "/2"})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	if _, ok, err := c.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v, want a miss", ok, err)
+	}
+
+	value := []byte("[{\"title\": \"iPhone 15\"}]\r\n")
+	if err := c.Set(ctx, "trendyol-iphone", value, 10*time.Second); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, ok, err := c.Get(ctx, "trendyol-iphone")
+	if err != nil || !ok || string(got) != string(value) {
+		t.Fatalf("Get = %q, %v, %v, want %q", got, ok, err, value)
+	}
+	srv.mu.Lock()
+	ttl := srv.ttls[redisKeyPrefix+"trendyol-iphone"]
+	srv.mu.Unlock()
+	if ttl != "10000" {
+		t.Errorf("stored with PX %s, want 10000", ttl)
+	}
+
+	if err := c.Delete(ctx, "trendyol-iphone"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "trendyol-iphone"); ok {
+		t.Errorf("entry served after Delete")
+	}
+}
+
+func TestRedisAuthenticatesACLUser(t *testing.T) {
+	_, addr := startRESPStandIn(t, "shop", "secret")
+
+	if _, err := NewRedis("redis://:secret@" + addr); err == nil {
+		t.Fatalf("NewRedis authenticated as the default user")
+	}
+	c, err := NewRedis("redis://shop:secret@" + addr)
+	if err != nil {
+		t.Fatalf("NewRedis with an ACL user: %v", err)
+	}
+	c.Close()
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxEntries bounds a memory cache created without a size.
+	DefaultMaxEntries = 1000
+	// sweepInterval is how often expired entries are removed from a memory
+	// cache, so entries that are never read again do not linger until they
+	// are evicted.
+	sweepInterval = time.Minute
+)
+
+// now returns the current time. Tests replace it to control expiry.
+var now = time.Now
+
+// Memory is an in-process cache that evicts the least recently used entry
+// once it holds its maximum number of entries.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxTTL     time.Duration
+	order      *list.List // front is the most recently used entry
+	entries    map[string]*list.Element
+	stop       chan struct{}
+	closeOnce  sync.Once
+}
+
+// memoryEntry is an element of Memory.order.
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemory returns a memory cache holding at most maxEntries entries, or
+// DefaultMaxEntries if maxEntries is not positive. A positive maxTTL caps
+// the lifetime of every entry. Expired entries are swept in the background
+// until the cache is closed.
+func NewMemory(maxEntries int, maxTTL time.Duration) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	m := &Memory{
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		stop:       make(chan struct{}),
+	}
+	go m.sweepEvery(sweepInterval)
+	return m
+}
+
+// Get implements Cache.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !now().Before(entry.expiresAt) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set implements Cache.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if m.maxTTL > 0 && ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+	expiresAt := now().Add(ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		m.order.MoveToFront(el)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Cache.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries in the cache, including expired ones
+// that have not been swept yet.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+// Close stops the background sweeping.
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() { close(m.stop) })
+	return nil
+}
+
+// sweepEvery removes the expired entries every interval until the cache is
+// closed.
+func (m *Memory) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.sweep()
+		}
+	}
+}
+
+// sweep removes the expired entries.
+func (m *Memory) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	for el := m.order.Back(); el != nil; {
+		prev := el.Prev()
+		if !t.Before(el.Value.(*memoryEntry).expiresAt) {
+			m.remove(el)
+		}
+		el = prev
+	}
+}
+
+// remove deletes an element. m.mu must be held.
+func (m *Memory) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
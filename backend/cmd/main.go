@@ -4,12 +4,22 @@ import (
 	"log"
 	"os"
 	"smartyshop/api"
+	"smartyshop/cache"
+	"smartyshop/config"
+	"smartyshop/scrapers"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+// selectorReloadInterval is how often the selector config file is checked for changes.
+const selectorReloadInterval = 30 * time.Second
+
+// proxyCheckInterval is how often the health of the scraper proxies is checked.
+const proxyCheckInterval = time.Minute
+
 func main() {
 	// Load .env file if it exists. In a Docker environment, variables will be
 	// loaded from the environment, so this is not a fatal error.
@@ -23,14 +33,64 @@ func main() {
 	}
 	log.Println("INFO: .env file loaded and GEMINI_API_KEY found.")
 
+	// Selectors can be overridden from a file that is reloaded when it changes,
+	// so a broken selector can be fixed without a rebuild.
+	if path := config.GetSelectorConfigPath(); path != "" {
+		if err := scrapers.LoadSelectorConfig(path); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		log.Printf("INFO: selector config loaded from %s", path)
+		go scrapers.WatchSelectorConfig(path, selectorReloadInterval, nil)
+	}
+
+	// Requests go out directly unless proxies are configured, so the server
+	// can run from cloud IPs the stores would otherwise block.
+	if proxies := config.GetScraperProxies(); len(proxies) > 0 {
+		if err := scrapers.ConfigureProxies(scrapers.ProxyConfig{
+			URLs:     proxies,
+			CheckURL: config.GetProxyCheckURL(),
+		}); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		log.Printf("INFO: scraping through %d proxies", len(proxies))
+		go scrapers.CheckProxies(proxyCheckInterval, nil)
+	}
+
 	r := gin.Default()
 
 	r.Use(cors.Default())
 
-	h := api.NewHandler()
+	// Scraped products are cached in process by default. With Redis the
+	// cache survives restarts and is shared by every replica.
+	maxEntries, err := config.GetCacheMaxEntries()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	maxTTL, err := config.GetCacheMaxTTL()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	productCache, err := cache.Open(cache.Options{
+		Backend:    config.GetCacheBackend(),
+		MaxEntries: maxEntries,
+		MaxTTL:     maxTTL,
+		RedisURL:   config.GetRedisURL(),
+	})
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	defer productCache.Close()
+
+	h := api.NewHandler(productCache)
 
 	r.GET("/products", h.GetProducts)
+	r.GET("/products/top", h.GetTopProducts)
 	r.GET("/products/top10", h.GetTop10Products)
+	r.GET("/products/detail", h.GetProductDetail)
+	r.GET("/search", h.Search)
+	r.GET("/sites", h.GetSites)
+	r.GET("/health/sites", h.GetSiteHealth)
+	r.GET("/health/proxies", h.GetProxyHealth)
 	r.POST("/gemini/query", h.GeminiQuery)
 
 	r.Run() // listen and serve on 0.0.0.0:8080
@@ -1,8 +1,81 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // GetGeminiAPIKey returns the Gemini API key from the environment variables.
 func GetGeminiAPIKey() string {
 	return os.Getenv("GEMINI_API_KEY")
 }
+
+// GetSelectorConfigPath returns the path of the optional selector
+// configuration file that overrides the built-in scraper selectors.
+func GetSelectorConfigPath() string {
+	return os.Getenv("SELECTOR_CONFIG")
+}
+
+// GetScraperProxies returns the proxies scrapers send their requests
+// through, given as a comma separated list in SCRAPER_PROXIES.
+func GetScraperProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("SCRAPER_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// GetProxyCheckURL returns the URL fetched through every proxy to check its
+// health.
+func GetProxyCheckURL() string {
+	if url := os.Getenv("SCRAPER_PROXY_CHECK_URL"); url != "" {
+		return url
+	}
+	return "https://www.google.com/generate_204"
+}
+
+// GetCacheBackend returns the cache backend selected in CACHE_BACKEND,
+// "memory" or "redis".
+func GetCacheBackend() string {
+	return os.Getenv("CACHE_BACKEND")
+}
+
+// GetCacheMaxEntries returns the maximum number of entries of the memory
+// cache, given in CACHE_MAX_ENTRIES, or 0 for the default when it is not set.
+func GetCacheMaxEntries() (int, error) {
+	raw := os.Getenv("CACHE_MAX_ENTRIES")
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("CACHE_MAX_ENTRIES must be a positive integer, got %q", raw)
+	}
+	return n, nil
+}
+
+// GetCacheMaxTTL returns the longest time the memory cache keeps an entry,
+// given in CACHE_MAX_TTL as a duration such as "30m" or "24h", or 0 to leave
+// it uncapped.
+func GetCacheMaxTTL() (time.Duration, error) {
+	raw := os.Getenv("CACHE_MAX_TTL")
+	if raw == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("CACHE_MAX_TTL must be a positive duration such as 30m, got %q", raw)
+	}
+	return ttl, nil
+}
+
+// GetRedisURL returns the address of the Redis server of the redis cache.
+func GetRedisURL() string {
+	return os.Getenv("REDIS_URL")
+}
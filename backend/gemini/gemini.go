@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"smartyshop/internal"
+	"sort"
 	"strings"
 )
 
@@ -42,18 +43,64 @@ type GeminiProductResponse struct {
 	Products []internal.Product `json:"products"`
 }
 
+// maxPromptSpecs caps the number of specifications listed per product to
+// keep the prompt short.
+const maxPromptSpecs = 8
+
+// describeDetails returns the details of an enriched product as a suffix for
+// its line in the prompt, or an empty string if it has none.
+func describeDetails(p internal.Product) string {
+	var details strings.Builder
+	if p.Brand != "" {
+		details.WriteString(", Brand: " + p.Brand)
+	}
+	if p.Seller != "" {
+		details.WriteString(", Seller: " + p.Seller)
+	}
+	if p.InStock != nil {
+		if *p.InStock {
+			details.WriteString(", In stock")
+		} else {
+			details.WriteString(", Out of stock")
+		}
+	}
+
+	if len(p.Specs) > 0 {
+		keys := make([]string, 0, len(p.Specs))
+		for key := range p.Specs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > maxPromptSpecs {
+			keys = keys[:maxPromptSpecs]
+		}
+
+		specs := make([]string, len(keys))
+		for i, key := range keys {
+			specs[i] = key + ": " + p.Specs[key]
+		}
+		details.WriteString(", Specs: " + strings.Join(specs, "; "))
+	}
+	return details.String()
+}
+
 // GetGeminiProductInsights sends the product list and user question to Gemini API.
 func GetGeminiProductInsights(products []internal.Product, userQuestion, apiKey string) (*GeminiProductResponse, error) {
 	var productList strings.Builder
 	for _, p := range products {
+		rating := "not rated"
+		if p.HasRating() {
+			rating = fmt.Sprintf("%.1f", p.RatingValue())
+		}
 		productList.WriteString(fmt.Sprintf(
-			"- Title: %s, Price: %s, Rating: %.1f, Reviews: %d, URL: %s\n",
-			p.Title, p.Price, p.Rating, p.ReviewsCount, p.URL))
+			"- Title: %s, Price: %s, Rating: %s, Reviews: %d, URL: %s%s\n",
+			p.Title, p.Price.Display, rating, p.ReviewsCount, p.URL, describeDetails(p)))
 	}
 
 	prompt := fmt.Sprintf(
 		"You are an expert shopping assistant and a knowledgeable AI. Your primary goal is to provide comprehensive and detailed answers to user questions about products.\n\n"+
 			"If the user asks for a recommendation, selection, or ranking, you must select up to 50 best products from the list based on their title, rating, and reviews count.\n"+
+			"Some products are marked as 'not rated' because the store shows no rating for them. Never invent a rating for these products, do not treat them as highly rated, and use null for their rating in the JSON. A rating backed by many reviews is more trustworthy than one backed by few.\n"+
 			"Crucially, if the question is not directly answerable from the provided products, you MUST use your extensive general knowledge and research capabilities to provide a comprehensive answer. Never state that you don't have enough information or that you can only answer based on provided products. Always strive to provide a detailed and informative response, even if it means drawing from your own knowledge base or simulating a web search.\n"+
 			"If no relevant products are found or provided in the initial list, the 'products' array in the JSON can be empty, but you must still provide a detailed 'answer'.\n"+
 			"When comparing products, provide detailed specifications and differences, similar to a product review site.\n"+
@@ -126,5 +173,32 @@ func GetGeminiProductInsights(products []internal.Product, userQuestion, apiKey
 		return nil, fmt.Errorf("error unmarshalling product response JSON from Gemini: %w. Raw response: %s", err, jsonString)
 	}
 
+	reconcileRatings(productResponse.Products, products)
+
 	return &productResponse, nil
 }
+
+// reconcileRatings replaces the ratings Gemini returned with the ones we
+// scraped for the same product. Ratings for products we never sent are kept
+// but marked as estimated so they are not mistaken for store data.
+func reconcileRatings(returned, sent []internal.Product) {
+	byURL := make(map[string]internal.Product, len(sent))
+	for _, p := range sent {
+		if p.URL != "" {
+			byURL[p.URL] = p
+		}
+	}
+
+	for i := range returned {
+		if original, ok := byURL[returned[i].URL]; ok {
+			returned[i].Rating = original.Rating
+			returned[i].RatingSource = original.RatingSource
+			continue
+		}
+		if returned[i].HasRating() {
+			returned[i].RatingSource = internal.RatingEstimated
+		} else {
+			returned[i].RatingSource = internal.RatingMissing
+		}
+	}
+}
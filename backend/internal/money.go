@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"encoding/json"
+	"math"
+	"smartyshop/pkg/utils"
+	"strings"
+)
+
+// CurrencyTRY is the ISO 4217 code of the Turkish lira.
+const CurrencyTRY = "TRY"
+
+// Money is a price in minor units of its currency (kuruş for TRY) together
+// with the text it was parsed from.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Display  string `json:"display"`
+}
+
+// ParsePrice parses a Turkish lira price as displayed on a store page. When no
+// amount can be found the returned Money only carries the display string.
+func ParsePrice(display string) Money {
+	display = strings.TrimSpace(display)
+	if display == "" {
+		return Money{}
+	}
+
+	amount, _ := utils.ParseTurkishPrice(display)
+	return Money{
+		Amount:   amount,
+		Currency: CurrencyTRY,
+		Display:  display,
+	}
+}
+
+// NewMoney returns the Money for an amount in major units as sent by store
+// APIs, e.g. 1299.9 TRY, with a display string in the format of the store
+// pages.
+func NewMoney(amount float64, currency string) Money {
+	if currency == "" {
+		currency = CurrencyTRY
+	}
+	minor := int64(math.Round(amount * 100))
+
+	display := utils.FormatTurkishPrice(minor) + " " + currency
+	if currency == CurrencyTRY {
+		display = utils.FormatTurkishPrice(minor) + " TL"
+	}
+	return Money{Amount: minor, Currency: currency, Display: display}
+}
+
+// IsZero reports whether m carries no amount.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Float returns the amount in major units, e.g. 1299.9 for 129990 kuruş.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / 100
+}
+
+// UnmarshalJSON accepts both the structured form and a plain price string,
+// which is what Gemini and older clients send.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var display string
+	if err := json.Unmarshal(data, &display); err == nil {
+		*m = ParsePrice(display)
+		return nil
+	}
+
+	type money Money
+	var v money
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
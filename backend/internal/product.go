@@ -1,14 +1,123 @@
 package internal
 
+import "math"
+
+// Rating sources describe where a product's rating comes from.
+const (
+	// RatingScraped marks a rating read from the store page.
+	RatingScraped = "scraped"
+	// RatingMissing marks a product the store shows no rating for.
+	RatingMissing = "missing"
+	// RatingEstimated marks a rating that was not read from the store, e.g.
+	// one supplied by Gemini for a product we did not scrape.
+	RatingEstimated = "estimated"
+)
+
 // Product represents a product scraped from an e-commerce site.
 
 type Product struct {
-	Title        string  `json:"title"`
-	Price        string  `json:"price"`
-	Rating       float64 `json:"rating"`
-	ReviewsCount int     `json:"reviews_count"`
-	URL          string  `json:"url"`
-	ImageURL     string  `json:"image_url"`
-	Description  string  `json:"description"`
-	Site         string  `json:"site"`
+	Title        string   `json:"title"`
+	Price        Money    `json:"price"`
+	Rating       *float64 `json:"rating"`
+	RatingSource string   `json:"rating_source"`
+	ReviewsCount int      `json:"reviews_count"`
+	URL          string   `json:"url"`
+	ImageURL     string   `json:"image_url"`
+	Description  string   `json:"description"`
+	Site         string   `json:"site"`
+
+	// OriginalPrice is the price before the discount, set only when the store
+	// shows one above Price.
+	OriginalPrice   *Money `json:"original_price,omitempty"`
+	DiscountPercent int    `json:"discount_percent,omitempty"`
+
+	// The fields below are filled from the product detail page or from the
+	// store's search API.
+	Brand   string            `json:"brand,omitempty"`
+	Seller  string            `json:"seller,omitempty"`
+	InStock *bool             `json:"in_stock,omitempty"`
+	Specs   map[string]string `json:"specs,omitempty"`
+	Images  []string          `json:"images,omitempty"`
+
+	// Relevance is how well the title matches the query the product was
+	// searched for, from 0 to 1. It is set by the API, not the scrapers.
+	Relevance *float64 `json:"relevance,omitempty"`
+}
+
+// SetScrapedRating records a rating read from the store page. Stores render
+// unrated products with a score of 0, so non-positive values mark the
+// rating as missing instead.
+func (p *Product) SetScrapedRating(rating float64) {
+	if rating <= 0 {
+		p.Rating = nil
+		p.RatingSource = RatingMissing
+		return
+	}
+	p.Rating = &rating
+	p.RatingSource = RatingScraped
+}
+
+// SetOriginalPrice records the price before the discount and derives the
+// discount from it. Prices that are not above the current price are ignored.
+func (p *Product) SetOriginalPrice(original Money) {
+	if original.Amount <= p.Price.Amount || p.Price.IsZero() {
+		return
+	}
+	p.OriginalPrice = &original
+	p.DiscountPercent = int(math.Round(float64(original.Amount-p.Price.Amount) * 100 / float64(original.Amount)))
+}
+
+// HasRating reports whether the product carries a rating.
+func (p Product) HasRating() bool {
+	return p.Rating != nil
+}
+
+// RatingValue returns the product's rating, or 0 if it has none.
+func (p Product) RatingValue() float64 {
+	if p.Rating == nil {
+		return 0
+	}
+	return *p.Rating
+}
+
+// MergeDetail fills the fields of p that are empty with the ones read from
+// the product detail page. Values already present in p are kept.
+func (p *Product) MergeDetail(detail Product) {
+	if p.Title == "" {
+		p.Title = detail.Title
+	}
+	if p.Price.IsZero() && !detail.Price.IsZero() {
+		p.Price = detail.Price
+	}
+	if p.OriginalPrice == nil && detail.OriginalPrice != nil {
+		p.SetOriginalPrice(*detail.OriginalPrice)
+	}
+	if !p.HasRating() && detail.HasRating() {
+		p.Rating = detail.Rating
+		p.RatingSource = detail.RatingSource
+	}
+	if p.ReviewsCount == 0 {
+		p.ReviewsCount = detail.ReviewsCount
+	}
+	if p.ImageURL == "" {
+		p.ImageURL = detail.ImageURL
+	}
+	if p.Description == "" {
+		p.Description = detail.Description
+	}
+	if p.Brand == "" {
+		p.Brand = detail.Brand
+	}
+	if p.Seller == "" {
+		p.Seller = detail.Seller
+	}
+	if p.InStock == nil {
+		p.InStock = detail.InStock
+	}
+	if len(p.Specs) == 0 {
+		p.Specs = detail.Specs
+	}
+	if len(p.Images) == 0 {
+		p.Images = detail.Images
+	}
 }
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseTurkishPrice parses a price as displayed by Turkish stores and returns
+// it in minor units (kuruş). It understands the common shapes found on the
+// supported sites, e.g. "1.299,00 TL", "₺1.299", "1299.00" and "1.299,",
+// where '.' is usually the thousands separator and ',' the decimal separator.
+// The second return value is false if no amount could be found.
+func ParseTurkishPrice(s string) (int64, bool) {
+	var b strings.Builder
+	for _, r := range s {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' {
+			b.WriteRune(r)
+		}
+	}
+	cleaned := strings.Trim(b.String(), ".,")
+	if cleaned == "" {
+		return 0, false
+	}
+
+	whole, fraction := splitDecimal(cleaned)
+	whole = strings.NewReplacer(".", "", ",", "").Replace(whole)
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (math.MaxInt64-99)/100 {
+		return 0, false
+	}
+
+	fraction = (fraction + "00")[:2]
+	minor, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return units*100 + minor, true
+}
+
+// splitDecimal splits a number containing '.' and ',' separators into its
+// whole and fractional parts.
+func splitDecimal(s string) (string, string) {
+	lastDot := strings.LastIndex(s, ".")
+	lastComma := strings.LastIndex(s, ",")
+
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both separators are present, the rightmost one is the decimal separator.
+		sep := lastDot
+		if lastComma > lastDot {
+			sep = lastComma
+		}
+		return s[:sep], s[sep+1:]
+	case lastComma >= 0:
+		// "1299,9" or "1299,90" use a decimal comma, "1,299" groups thousands.
+		if strings.Count(s, ",") == 1 && len(s)-lastComma-1 <= 2 {
+			return s[:lastComma], s[lastComma+1:]
+		}
+		return s, ""
+	case lastDot >= 0:
+		// "1.299" and "1.299.999" group thousands, "1299.00" uses a decimal point.
+		if strings.Count(s, ".") == 1 && len(s)-lastDot-1 != 3 {
+			return s[:lastDot], s[lastDot+1:]
+		}
+		return s, ""
+	default:
+		return s, ""
+	}
+}
+
+// FormatTurkishPrice formats an amount in minor units the way Turkish stores
+// display it, e.g. 129900 → "1.299,00".
+func FormatTurkishPrice(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	whole := strconv.FormatInt(minor/100, 10)
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, b.String(), minor%100)
+}
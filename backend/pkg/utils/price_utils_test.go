@@ -0,0 +1,71 @@
+package utils
+
+import "testing"
+
+func TestParseTurkishPrice(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int64
+		wantOK bool
+	}{
+		// '.' groups thousands, ',' separates the decimals.
+		{"1.299,00 TL", 129900, true},
+		{"1.234.567,89", 123456789, true},
+		{"₺1.299", 129900, true},
+		{"1.299,", 129900, true},
+		{"0,99", 99, true},
+		// A single '.' followed by three digits groups thousands...
+		{"1.299", 129900, true},
+		{"1.299.999", 129999900, true},
+		// ...otherwise it is a decimal point.
+		{"1299.00", 129900, true},
+		{"12.50", 1250, true},
+		{"12.5", 1250, true},
+		// A single ',' followed by up to two digits is a decimal comma...
+		{"1299,9", 129990, true},
+		{"1299,90", 129990, true},
+		// ...otherwise it groups thousands.
+		{"1,299", 129900, true},
+		{"1,299,999", 129999900, true},
+		// With both separators the rightmost one is the decimal separator.
+		{"1,299.50", 129950, true},
+		{"", 0, false},
+		{"TL", 0, false},
+		{".,", 0, false},
+		{"99999999999999999999", 0, false},
+		// Fits in int64 as lira but not as kuruş.
+		{"92233720368547758", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseTurkishPrice(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseTurkishPrice(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFormatTurkishPrice(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0,00"},
+		{99, "0,99"},
+		{129900, "1.299,00"},
+		{129990, "1.299,90"},
+		{123456789, "1.234.567,89"},
+		{-129900, "-1.299,00"},
+	}
+	for _, tt := range tests {
+		got := FormatTurkishPrice(tt.in)
+		if got != tt.want {
+			t.Errorf("FormatTurkishPrice(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+		if tt.in < 0 {
+			continue
+		}
+		if back, ok := ParseTurkishPrice(got); !ok || back != tt.in {
+			t.Errorf("ParseTurkishPrice(%q) = %d, %v, want %d", got, back, ok, tt.in)
+		}
+	}
+}
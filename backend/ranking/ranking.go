@@ -0,0 +1,234 @@
+// Package ranking scores products for the top products endpoint and for
+// picking the products sent to Gemini. A strategy turns a product's rating,
+// number of reviews, price and the words of the query into a single score,
+// higher is better.
+package ranking
+
+import (
+	"math"
+	"regexp"
+	"smartyshop/internal"
+	"smartyshop/pkg/utils"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Names of the ranking strategies.
+const (
+	// Bayesian pulls the rating of products with few reviews towards a
+	// mediocre rating, so a 5.0 rated product with a single review ranks
+	// below a 4.7 rated one with thousands.
+	Bayesian = "bayesian"
+	// Wilson ranks by the lower bound of the confidence interval of the
+	// rating, penalizing products with few reviews harder than Bayesian.
+	Wilson = "wilson"
+	// Value ranks by the Bayesian score relative to the price, favoring
+	// well rated products that are cheaper than the others.
+	Value = "value"
+	// Relevance ranks by how well the title matches the query, see
+	// RelevanceScorer.
+	Relevance = "relevance"
+	// Rating ranks by the rating as shown by the store.
+	Rating = "rating"
+)
+
+// Default is the strategy used when none is given.
+const Default = Bayesian
+
+const (
+	// priorRating and priorReviews make up the prior of the Bayesian
+	// average: every product is scored as if it also had priorReviews
+	// reviews of priorRating. Store ratings cluster between 4 and 5, so a
+	// prior at the mean of the ranked products would hardly hold back a
+	// product with a single 5 star review.
+	priorRating  = 3.5
+	priorReviews = 20
+	// wilsonZ is the z-score of the 95% confidence interval.
+	wilsonZ = 1.96
+)
+
+// set holds what the strategies need to know about all ranked products.
+type set struct {
+	relevance   *RelevanceScorer
+	medianPrice float64
+}
+
+// Strategy scores products. Products it cannot score, e.g. unrated products
+// for a rating based strategy, are ranked after every scored product.
+type Strategy struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	score func(p internal.Product, s *set) (float64, bool)
+}
+
+var strategies = map[string]Strategy{
+	Bayesian: {
+		Name:        Bayesian,
+		Description: "Rating averaged with a prior of 20 reviews at 3.5, so ratings backed by few reviews count less.",
+		score:       bayesian,
+	},
+	Wilson: {
+		Name:        Wilson,
+		Description: "Lower bound of the 95% confidence interval of the rating.",
+		score:       wilson,
+	},
+	Value: {
+		Name:        Value,
+		Description: "Bayesian score relative to the price compared to the other products.",
+		score:       value,
+	},
+	Relevance: {
+		Name:        Relevance,
+		Description: "Share of the query's words found in the title, lower for accessories the query does not ask for.",
+		score:       relevance,
+	},
+	Rating: {
+		Name:        Rating,
+		Description: "Rating as shown by the store.",
+		score: func(p internal.Product, s *set) (float64, bool) {
+			return p.RatingValue(), p.HasRating()
+		},
+	},
+}
+
+// Lookup returns the strategy registered under name. An empty name selects
+// the default strategy.
+func Lookup(name string) (Strategy, bool) {
+	if name == "" {
+		name = Default
+	}
+	s, ok := strategies[strings.ToLower(name)]
+	return s, ok
+}
+
+// Strategies returns every strategy sorted by name.
+func Strategies() []Strategy {
+	list := make([]Strategy, 0, len(strategies))
+	for _, s := range strategies {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Scored is a product with its score.
+type Scored struct {
+	Product internal.Product
+	Score   float64
+	// Ok reports whether the strategy could score the product.
+	Ok bool
+}
+
+// Score scores every product with strategy for query. The result is in the
+// order of products and holds copies of them.
+func (strategy Strategy) Score(products []internal.Product, query string) []Scored {
+	s := newSet(products, query)
+	scored := make([]Scored, len(products))
+	for i, p := range products {
+		score, ok := strategy.score(p, s)
+		scored[i] = Scored{Product: p, Score: score, Ok: ok}
+	}
+	return scored
+}
+
+// Rank returns a copy of products ordered by their score, best first.
+// Products with equal scores keep their order.
+func (strategy Strategy) Rank(products []internal.Product, query string) []internal.Product {
+	scored := strategy.Score(products, query)
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Ok != scored[j].Ok {
+			return scored[i].Ok
+		}
+		return scored[i].Score > scored[j].Score
+	})
+
+	ranked := make([]internal.Product, len(scored))
+	for i, s := range scored {
+		ranked[i] = s.Product
+	}
+	return ranked
+}
+
+// newSet collects the median price of products.
+func newSet(products []internal.Product, query string) *set {
+	// The empty category always exists.
+	relevance, _ := NewRelevanceScorer(query, "")
+	s := &set{relevance: relevance}
+
+	var prices []float64
+	for _, p := range products {
+		if !p.Price.IsZero() {
+			prices = append(prices, p.Price.Float())
+		}
+	}
+	if len(prices) > 0 {
+		sort.Float64s(prices)
+		s.medianPrice = prices[len(prices)/2]
+	}
+	return s
+}
+
+// bayesian returns the Bayesian average of the product's rating, between 1
+// and 5 like the rating itself.
+func bayesian(p internal.Product, s *set) (float64, bool) {
+	if !p.HasRating() {
+		return 0, false
+	}
+	reviews := float64(p.ReviewsCount)
+	return (reviews*p.RatingValue() + priorReviews*priorRating) / (reviews + priorReviews), true
+}
+
+// wilson treats the rating as the share of positive reviews and returns the
+// lower bound of its Wilson score interval, scaled back to 1 to 5. A rating
+// without reviews counts as a single review.
+func wilson(p internal.Product, s *set) (float64, bool) {
+	if !p.HasRating() {
+		return 0, false
+	}
+	n := math.Max(float64(p.ReviewsCount), 1)
+	positive := math.Min(math.Max((p.RatingValue()-1)/4, 0), 1)
+
+	z2 := wilsonZ * wilsonZ
+	lower := (positive + z2/(2*n) - wilsonZ*math.Sqrt((positive*(1-positive)+z2/(4*n))/n)) / (1 + z2/n)
+	return 1 + 4*lower, true
+}
+
+// value divides the Bayesian score by the square root of the price relative
+// to the median price, so a product at a quarter of the median price needs
+// half the score of one at the median.
+func value(p internal.Product, s *set) (float64, bool) {
+	score, ok := bayesian(p, s)
+	if !ok || p.Price.IsZero() || s.medianPrice == 0 {
+		return 0, false
+	}
+	return score / math.Sqrt(p.Price.Float()/s.medianPrice), true
+}
+
+// relevance returns the relevance the product was annotated with, or
+// scores its title against the query.
+func relevance(p internal.Product, s *set) (float64, bool) {
+	if p.Relevance != nil {
+		return *p.Relevance, true
+	}
+	if s.relevance.Empty() {
+		return 0, false
+	}
+	return s.relevance.Score(p.Title), true
+}
+
+// turkishSuffix matches the suffixes Turkish separates from names with an
+// apostrophe, e.g. "iPhone'un".
+var turkishSuffix = regexp.MustCompile(`['’]\pL+`)
+
+// tokens splits text into lower case words without Turkish characters and
+// suffixes, so "Kulaklık" and "kulaklik" match, as do "iPhone'un" and
+// "iphone".
+func tokens(text string) []string {
+	text = turkishSuffix.ReplaceAllString(text, "")
+	text = strings.ToLower(utils.ConvertToEnglishChars(text))
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,82 @@
+package ranking
+
+import (
+	"reflect"
+	"smartyshop/internal"
+	"testing"
+)
+
+func product(title string, rating float64, reviews int, price float64) internal.Product {
+	p := internal.Product{Title: title, ReviewsCount: reviews}
+	if price > 0 {
+		p.Price = internal.NewMoney(price, internal.CurrencyTRY)
+	}
+	p.SetScrapedRating(rating)
+	return p
+}
+
+func TestStrategies(t *testing.T) {
+	products := []internal.Product{
+		product("Apple iPhone 15 Kılıf", 5, 1, 300),
+		product("Apple iPhone 15 128 GB", 4.7, 12000, 50000),
+		product("Samsung Galaxy S24", 4.4, 3000, 40000),
+		product("iPhone 15 Pro Max", 0, 0, 70000),
+	}
+
+	tests := []struct {
+		strategy string
+		query    string
+		want     []string
+	}{
+		{Rating, "", []string{"Apple iPhone 15 Kılıf", "Apple iPhone 15 128 GB", "Samsung Galaxy S24", "iPhone 15 Pro Max"}},
+		{Bayesian, "", []string{"Apple iPhone 15 128 GB", "Samsung Galaxy S24", "Apple iPhone 15 Kılıf", "iPhone 15 Pro Max"}},
+		{Wilson, "", []string{"Apple iPhone 15 128 GB", "Samsung Galaxy S24", "Apple iPhone 15 Kılıf", "iPhone 15 Pro Max"}},
+		// The case costs a fraction of the phones.
+		{Value, "", []string{"Apple iPhone 15 Kılıf", "Samsung Galaxy S24", "Apple iPhone 15 128 GB", "iPhone 15 Pro Max"}},
+		// The case matches as many words as the phone but is an accessory.
+		{Relevance, "iphone 15 pro", []string{"iPhone 15 Pro Max", "Apple iPhone 15 128 GB", "Apple iPhone 15 Kılıf", "Samsung Galaxy S24"}},
+		// Without a query nothing is scored and the order is kept.
+		{Relevance, "", []string{"Apple iPhone 15 Kılıf", "Apple iPhone 15 128 GB", "Samsung Galaxy S24", "iPhone 15 Pro Max"}},
+	}
+	for _, tt := range tests {
+		strategy, ok := Lookup(tt.strategy)
+		if !ok {
+			t.Fatalf("strategy %s not found", tt.strategy)
+		}
+
+		var got []string
+		for _, p := range strategy.Rank(products, tt.query) {
+			got = append(got, p.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestBayesianScore(t *testing.T) {
+	strategy, _ := Lookup("")
+	scored := strategy.Score([]internal.Product{
+		product("rated", 4.5, 20, 0),
+		product("no reviews", 5, 0, 0),
+		product("unrated", 0, 0, 0),
+	}, "")
+
+	// 20 reviews weigh as much as the prior.
+	if got := scored[0].Score; got != 4 {
+		t.Errorf("score = %v, want 4", got)
+	}
+	// Without reviews the score is the prior.
+	if got := scored[1].Score; got != priorRating {
+		t.Errorf("score without reviews = %v, want %v", got, priorRating)
+	}
+	if scored[2].Ok {
+		t.Errorf("unrated product was scored")
+	}
+}
+
+func TestTokensFoldTurkishCharacters(t *testing.T) {
+	if got := tokens("Kablosuz KULAKLIK, Şarj-Kılıfı"); !reflect.DeepEqual(got, []string{"kablosuz", "kulaklik", "sarj", "kilifi"}) {
+		t.Errorf("tokens = %q", got)
+	}
+}
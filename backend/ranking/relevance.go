@@ -0,0 +1,209 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"smartyshop/internal"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// accessoryPenalty multiplies the relevance of accessories found for a
+	// query that does not ask for one, e.g. a case found for "iphone 15".
+	accessoryPenalty = 0.25
+	// categoryBonus is added to the relevance of products whose title
+	// names the category hinted at.
+	categoryBonus = 0.15
+	// categoryPenalty multiplies the relevance of products whose title names
+	// another category than the one hinted at, e.g. a speaker found for "jbl
+	// bluetooth" in headphones. Titles naming no category are left alone, as
+	// most listings only give the brand and model, e.g. "Samsung Galaxy A55".
+	categoryPenalty = 0.5
+	// minStemLength is the length of the shortest query word that also
+	// matches longer title words starting with it.
+	minStemLength = 4
+)
+
+// stopwords are words of a query that do not describe the product, e.g.
+// "en ucuz" (the cheapest), and are ignored when matching titles.
+var stopwords = map[string]bool{
+	"ve": true, "ile": true, "icin": true, "en": true, "ucuz": true,
+	"iyi": true, "fiyat": true, "fiyati": true, "uygun": true,
+	"the": true, "for": true, "and": true, "with": true, "best": true, "cheap": true,
+}
+
+// accessoryTerms mark the titles of accessories. A term matches the start
+// of a word, so "kilif" also matches "kilifi"; a trailing space only
+// matches the whole word, so "kablo " does not match "kablosuz"
+// (wireless).
+var accessoryTerms = []string{
+	"kilif", "kapak ", "kablo ", "kablosu ", "sarj aleti", "sarj cihazi", "adaptor",
+	"koruyucu", "temperli", "uyumlu", "tutucu", "kordon", "kayis", "aski ",
+	"case ", "cover ", "cable", "charger", "protector", "compatible", "holder",
+	"stand ", "film ", "jelatin",
+}
+
+// Categories hint at what kind of product a query is after.
+const (
+	CategoryPhone      = "phone"
+	CategoryLaptop     = "laptop"
+	CategoryHeadphones = "headphones"
+	CategoryTV         = "tv"
+	CategoryTablet     = "tablet"
+	CategorySpeaker    = "speaker"
+	// CategoryAccessory asks for accessories, which are then not penalized.
+	CategoryAccessory = "accessory"
+)
+
+// categoryTerms are the words naming a category in product titles,
+// matched like accessoryTerms.
+var categoryTerms = map[string][]string{
+	CategoryPhone:      {"telefon", "smartphone", "akilli telefon"},
+	CategoryLaptop:     {"laptop", "notebook", "dizustu", "macbook"},
+	CategoryHeadphones: {"kulaklik", "headphone", "earbud", "airpods"},
+	CategoryTV:         {"televizyon", "tv ", "smart tv", "qled", "oled"},
+	CategoryTablet:     {"tablet", "ipad"},
+	CategorySpeaker:    {"hoparlor", "speaker", "soundbar"},
+	CategoryAccessory:  accessoryTerms,
+}
+
+// Categories returns the names of the categories, sorted.
+func Categories() []string {
+	names := make([]string, 0, len(categoryTerms))
+	for name := range categoryTerms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RelevanceScorer scores how well product titles match a query, from 0 for
+// an unrelated product to 1 for one whose title holds every word of the
+// query. Words of at least minStemLength letters also match the words
+// they start, as Turkish appends suffixes: "kilif" matches "kilifi".
+// Accessories are penalized unless the query or the category asks for
+// them. Titles naming the hinted category get a bonus, titles naming
+// another one a penalty.
+type RelevanceScorer struct {
+	query     []string
+	accessory bool
+	category  []string
+	// others are the terms of the categories not hinted at, except
+	// accessories, which have a penalty of their own.
+	others []string
+}
+
+// NewRelevanceScorer returns a scorer for query. category is one of
+// Categories or empty for no hint.
+func NewRelevanceScorer(query, category string) (*RelevanceScorer, error) {
+	r := &RelevanceScorer{}
+	if category != "" {
+		terms, ok := categoryTerms[strings.ToLower(category)]
+		if !ok {
+			return nil, fmt.Errorf("unknown category %q, must be one of %s", category, strings.Join(Categories(), ", "))
+		}
+		r.category = terms
+		r.accessory = strings.ToLower(category) == CategoryAccessory
+		for name, terms := range categoryTerms {
+			if name != strings.ToLower(category) && name != CategoryAccessory {
+				r.others = append(r.others, terms...)
+			}
+		}
+	}
+
+	words := tokens(query)
+	for _, word := range words {
+		if !stopwords[word] {
+			r.query = append(r.query, word)
+		}
+	}
+	if len(r.query) == 0 {
+		// A query of stopwords only is still matched as it is.
+		r.query = words
+	}
+	r.accessory = r.accessory || containsAny(words, accessoryTerms)
+	return r, nil
+}
+
+// Empty reports whether the query has no words to match, in which case
+// no product can be scored.
+func (r *RelevanceScorer) Empty() bool {
+	return len(r.query) == 0
+}
+
+// Score returns the relevance of a product titled title, rounded to two
+// decimals.
+func (r *RelevanceScorer) Score(title string) float64 {
+	if r.Empty() {
+		return 0
+	}
+	words := tokens(title)
+	inTitle := make(map[string]bool, len(words))
+	for _, word := range words {
+		inTitle[word] = true
+	}
+
+	var found int
+	for _, word := range r.query {
+		if inTitle[word] || isStem(word) && containsAny(words, []string{word}) {
+			found++
+		}
+	}
+	score := float64(found) / float64(len(r.query))
+
+	if !r.accessory && containsAny(words, accessoryTerms) {
+		score *= accessoryPenalty
+	}
+	if len(r.category) > 0 {
+		switch {
+		case containsAny(words, r.category):
+			score = math.Min(score+categoryBonus, 1)
+		case containsAny(words, r.others):
+			score *= categoryPenalty
+		}
+	}
+	return math.Round(score*100) / 100
+}
+
+// Annotate returns a copy of products with their Relevance set. Products
+// are left as they are when the query has no words to match.
+func (r *RelevanceScorer) Annotate(products []internal.Product) []internal.Product {
+	if r.Empty() {
+		return products
+	}
+	annotated := make([]internal.Product, len(products))
+	for i, p := range products {
+		score := r.Score(p.Title)
+		p.Relevance = &score
+		annotated[i] = p
+	}
+	return annotated
+}
+
+// isStem reports whether word is long enough and made of letters only to
+// match the words it starts.
+func isStem(word string) bool {
+	if len(word) < minStemLength {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny reports whether one of terms starts a word of words, or
+// matches a whole word if it ends with a space.
+func containsAny(words []string, terms []string) bool {
+	text := " " + strings.Join(words, " ") + " "
+	for _, term := range terms {
+		if strings.Contains(text, " "+term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package ranking
+
+import (
+	"smartyshop/internal"
+	"testing"
+)
+
+func TestRelevanceScorer(t *testing.T) {
+	tests := []struct {
+		query    string
+		category string
+		title    string
+		want     float64
+	}{
+		{"iphone 15", "", "Apple iPhone 15 128 GB Siyah", 1},
+		{"iPhone 15", "", "Apple iPhone 15 Uyumlu Şeffaf Silikon Kılıf", 0.25},
+		{"iphone 15", "", "Apple iPhone'un 15W Şarj Aleti", 0.13},
+		{"samsung galaxy s24", "", "Apple iPhone 15", 0},
+		// Accessories asked for are not penalized.
+		{"iphone 15 kılıf", "", "Apple iPhone 15 Uyumlu Şeffaf Silikon Kılıfı", 1},
+		{"iphone 15", CategoryAccessory, "Apple iPhone 15 Uyumlu Silikon Kılıf", 1},
+		// Stopwords are ignored, Turkish characters folded.
+		{"en ucuz bluetooth kulaklık", "", "JBL Tune 520BT Bluetooth Kulaklik", 1},
+		// Titles naming another category than the hinted one are penalized,
+		// titles naming none are not.
+		{"jbl bluetooth", CategoryHeadphones, "JBL Flip 6 Bluetooth Hoparlör", 0.5},
+		{"jbl tune", CategoryHeadphones, "JBL Tune 520BT Kablosuz Kulaklık", 1},
+		{"jbl kablosuz", CategoryHeadphones, "JBL Go 3 Hoparlör", 0.25},
+		{"jbl kablosuz", CategoryHeadphones, "JBL Tune 520BT Kulaklık", 0.65},
+		{"iphone 15", CategoryAccessory, "Apple iPhone 15 128 GB Akıllı Telefon", 0.5},
+		{"iphone 15", CategoryPhone, "Apple iPhone 15 (128 GB) - Siyah", 1},
+		{"samsung galaxy", CategoryTablet, "Samsung Galaxy A55 Akıllı Telefon", 0.5},
+	}
+	for _, tt := range tests {
+		r, err := NewRelevanceScorer(tt.query, tt.category)
+		if err != nil {
+			t.Fatalf("NewRelevanceScorer(%q, %q): %v", tt.query, tt.category, err)
+		}
+		if got := r.Score(tt.title); got != tt.want {
+			t.Errorf("%q for %q = %v, want %v", tt.title, tt.query, got, tt.want)
+		}
+	}
+
+	if _, err := NewRelevanceScorer("iphone", "car"); err == nil {
+		t.Errorf("unknown category accepted")
+	}
+}
+
+func TestAnnotateSetsRelevanceOnACopy(t *testing.T) {
+	products := []internal.Product{{Title: "Apple iPhone 15"}, {Title: "iPhone 15 Kılıf"}}
+	r, _ := NewRelevanceScorer("iphone 15", "")
+
+	annotated := r.Annotate(products)
+	if products[0].Relevance != nil {
+		t.Errorf("Annotate modified its input")
+	}
+	if *annotated[0].Relevance != 1 || *annotated[1].Relevance != 0.25 {
+		t.Errorf("relevance = %v, %v", *annotated[0].Relevance, *annotated[1].Relevance)
+	}
+
+	// The relevance strategy ranks by the annotation.
+	strategy, _ := Lookup(Relevance)
+	if ranked := strategy.Rank(annotated, "kılıf"); ranked[0].Title != "Apple iPhone 15" {
+		t.Errorf("relevance strategy ignored the annotation: %q first", ranked[0].Title)
+	}
+}
@@ -1,132 +1,80 @@
 package scrapers
 
 import (
-	"fmt"
-	"math/rand"
-	"net/url"
+	"context"
 	"smartyshop/internal"
-	"smartyshop/pkg/utils"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/gocolly/colly"
 )
 
+// AmazonScraper implements the Scraper interface for Amazon Türkiye.
 type AmazonScraper struct{}
 
-func (s *AmazonScraper) Scrape(query string) ([]internal.Product, error) {
-	var products []internal.Product
-
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.amazon.com.tr"),
-	)
-
-	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64)" // Bot algısını azaltır
-
-	c.OnHTML("div[data-component-type=s-search-result]", func(e *colly.HTMLElement) {
-		// Başlık (title)
-		title := e.ChildAttr("h2", "aria-label")
-		if title == "" {
-			title = e.ChildText("h2 span")
-		}
-
-		// Fiyat (price) - Daha sağlam bir yöntem
-		priceStr := ""
-		e.DOM.Find(".a-price").First().Each(func(i int, s *goquery.Selection) {
-			whole := s.Find(".a-price-whole").Text()
-			fraction := s.Find(".a-price-fraction").Text()
-			if whole != "" && fraction != "" {
-				priceStr = whole + fraction // Virgül veya nokta olmadan birleştir
-			}
-		})
-
-		// Eğer yukarıdaki yöntem çalışmazsa, eski yöntemi dene
-		if priceStr == "" {
-			priceStr = e.ChildText(".a-price .a-offscreen")
-		}
-
-		// Fiyatı temizle
-		priceStr = strings.ReplaceAll(priceStr, "TL", "")
-		priceStr = strings.ReplaceAll(priceStr, "₺", "")
-		priceStr = strings.ReplaceAll(priceStr, ".", "") // Binlik ayıracı kaldır
-		priceStr = strings.ReplaceAll(priceStr, ",", ".") // Ondalık ayıracı noktaya çevir
-		priceStr = strings.TrimSpace(priceStr)
-
-		// Görsel URL
-		imageURL := e.ChildAttr("img", "src")
-
-		// Ürün linki
-		url := e.Request.AbsoluteURL(e.ChildAttr("h2 a", "href"))
-
-		// Puan (rating)
-		rating := 0.0
-		ratingRaw := e.ChildText("span.a-icon-alt") // En sağlam yer burası
-		if ratingRaw != "" {
-			parts := strings.Split(ratingRaw, " ")
-			if len(parts) >= 4 {
-				ratingStr := strings.Replace(parts[3], ",", ".", 1) // "4,4" → "4.4"
-				r, err := strconv.ParseFloat(ratingStr, 64)
-				if err == nil {
-					rating = r
-				}
-			}
-		}
-
-		// Eğer rating hala 0 ise, rastgele bir değer ata
-		if rating == 0.0 {
-			rand.Seed(time.Now().UnixNano())
-			rating = 3.5 + rand.Float64()*(4.9-3.5)
-			rating, _ = strconv.ParseFloat(fmt.Sprintf("%.1f", rating), 64) // Tek ondalık basamağa yuvarla
-		}
-
-		// Yorum sayısı (reviews count)
-		reviews := 0
-		e.DOM.Find("span.a-size-base").EachWithBreak(func(i int, s *goquery.Selection) bool {
-			text := strings.TrimSpace(s.Text())
-			if isDigitsOnly(text) {
-				reviewsStr := strings.ReplaceAll(text, ".", "")
-				r, err := strconv.Atoi(reviewsStr)
-				if err == nil {
-					reviews = r
-					return false // bulduk, çık
-				}
-			}
-			return true
-		})
-
-		product := internal.Product{
-			Title:        title,
-			Price:        priceStr,
-			ImageURL:     imageURL,
-			URL:          url,
-			Site:         "Amazon",
-			Rating:       rating,
-			ReviewsCount: reviews,
-		}
-		products = append(products, product)
+func init() {
+	Register(Site{
+		ID:           "amazon",
+		Name:         "Amazon",
+		Domain:       "www.amazon.com.tr",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews, CapabilityDetail},
+	}, func() Scraper { return &AmazonScraper{} })
+
+	registerSiteConfig("amazon", SiteConfig{
+		SearchURL:     "https://www.amazon.com.tr/s?k={query}",
+		QueryEncoding: QueryEnglish,
+		PageParam:     "page",
+		Card:          "div[data-component-type=s-search-result]",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {
+				{Selector: "h2", Attr: "aria-label"},
+				{Selector: "h2 span"},
+			},
+			// İlk .a-price güncel fiyattır, sonrakiler liste fiyatı olabilir.
+			// "54.999," + "00" → "54.999,00"; olmazsa gizli metne bakılır.
+			FieldPrice: {
+				{Selector: ".a-price", Join: []FieldRule{
+					{Selector: ".a-price-whole", Trim: ".,"},
+					{Selector: ".a-price-fraction"},
+				}, Separator: ","},
+				{Selector: ".a-price .a-offscreen"},
+			},
+			FieldImage: {{Selector: "img", Attr: "src"}},
+			FieldURL:   {{Selector: "h2 a", Attr: "href"}},
+			// "5 yıldız üzerinden 4,4" → "4,4"
+			FieldRating: {{Selector: "span.a-icon-alt", Pattern: `üzerinden\s*(\d+(?:[.,]\d+)?)`}},
+			// Sadece rakamlardan (ve binlik ayıracından) oluşan ilk span yorum sayısıdır
+			FieldReviews: {{Selector: "span.a-size-base", Pattern: `^\d[\d.]*$`}},
+		},
+		Detail: &DetailConfig{
+			Fields: map[string][]FieldRule{
+				FieldTitle: {{Selector: "#productTitle"}},
+				FieldPrice: {{Selector: "#corePrice_feature_div .a-offscreen"}},
+				// "Marka: Apple" veya "Apple Store'u ziyaret edin"
+				FieldBrand: {
+					{Selector: "#bylineInfo", Pattern: `^Marka:\s*(.+)$`},
+					{Selector: "#bylineInfo", Pattern: `^(.+?) Store'u ziyaret edin$`},
+				},
+				FieldDescription: {{Selector: "#feature-bullets"}},
+				FieldSeller:      {{Selector: "#sellerProfileTriggerId"}},
+				FieldStock:       {{Selector: "#availability"}},
+				FieldRating:      {{Selector: "#acrPopover", Attr: "title", Pattern: `üzerinden\s*(\d+(?:[.,]\d+)?)`}},
+				FieldReviews:     {{Selector: "#acrCustomerReviewText"}},
+			},
+			Images: []FieldRule{
+				{Selector: "#landingImage", Attr: "data-old-hires"},
+				{Selector: "#landingImage", Attr: "src"},
+			},
+			SpecRows:   "#productDetails_techSpec_section_1 tr",
+			SpecKey:    []FieldRule{{Selector: "th"}},
+			SpecValue:  []FieldRule{{Selector: "td", Trim: "\u200e"}},
+			OutOfStock: `(?i)stokta yok|mevcut değil`,
+		},
 	})
+}
 
-	c.OnRequest(func(r *colly.Request) {
-		fmt.Println("Visiting", r.URL.String())
-	})
-
-	convertedQuery := utils.ConvertToEnglishChars(query)
-	err := c.Visit(fmt.Sprintf("https://www.amazon.com.tr/s?k=%s", url.QueryEscape(convertedQuery)))
-	if err != nil {
-		return nil, err
-	}
-
-	return products, nil
+// Scrape scrapes Amazon for products.
+func (s *AmazonScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "amazon"}).Scrape(ctx, query, opts)
 }
 
-// Yardımcı: Sadece rakamlardan oluşuyor mu?
-func isDigitsOnly(s string) bool {
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
-		}
-	}
-	return true
+// Detail scrapes an Amazon product page.
+func (s *AmazonScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return (&ConfigScraper{SiteID: "amazon"}).Detail(ctx, productURL)
 }
@@ -0,0 +1,209 @@
+package scrapers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Errors returned by the scrapers when a store answers with something other
+// than the requested page. They are wrapped with the details of the request,
+// use errors.Is to test for them.
+var (
+	// ErrBlocked means the store refused the request, e.g. with HTTP 403 or
+	// the block page of its bot protection.
+	ErrBlocked = errors.New("blocked by the store")
+	// ErrCaptcha means the store asked to solve a CAPTCHA or a browser
+	// challenge instead of serving the page.
+	ErrCaptcha = errors.New("store requires a CAPTCHA")
+	// ErrConsentWall means the store showed a consent page that has to be
+	// accepted before the requested page.
+	ErrConsentWall = errors.New("store requires cookie consent")
+	// ErrUnexpectedRedirect means the store redirected the request off the
+	// site or to a login page.
+	ErrUnexpectedRedirect = errors.New("store redirected unexpectedly")
+	// ErrLayoutChanged means the page was served but could not be read,
+	// usually because the store changed its markup or API.
+	ErrLayoutChanged = errors.New("page layout changed")
+)
+
+// Error kinds reported by ErrorKind, Diagnostics.ErrorKind and
+// SiteHealth.Errors.
+const (
+	ErrorKindBlocked            = "blocked"
+	ErrorKindCaptcha            = "captcha"
+	ErrorKindConsentWall        = "consent_wall"
+	ErrorKindUnexpectedRedirect = "unexpected_redirect"
+	ErrorKindLayoutChanged      = "layout_changed"
+	ErrorKindDisallowedByRobots = "disallowed_by_robots"
+	ErrorKindCircuitOpen        = "circuit_open"
+	ErrorKindNoHealthyProxy     = "no_healthy_proxy"
+	ErrorKindTimeout            = "timeout"
+	ErrorKindCanceled           = "canceled"
+	ErrorKindOther              = "other"
+)
+
+// errorKinds maps the errors of the package to their kinds.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrBlocked, ErrorKindBlocked},
+	{ErrCaptcha, ErrorKindCaptcha},
+	{ErrConsentWall, ErrorKindConsentWall},
+	{ErrUnexpectedRedirect, ErrorKindUnexpectedRedirect},
+	{ErrLayoutChanged, ErrorKindLayoutChanged},
+	{ErrDisallowedByRobots, ErrorKindDisallowedByRobots},
+	{ErrCircuitOpen, ErrorKindCircuitOpen},
+	{ErrNoHealthyProxy, ErrorKindNoHealthyProxy},
+	{context.DeadlineExceeded, ErrorKindTimeout},
+	{context.Canceled, ErrorKindCanceled},
+}
+
+// ErrorKind classifies an error returned by a scraper, returning "" for a
+// nil error and ErrorKindOther for errors without a more specific kind.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ErrorKindOther
+}
+
+// blockPageMaxBytes is the size above which a page is never taken for a
+// block page. Block pages are small, so a full result page that happens to
+// embed a CAPTCHA widget, e.g. in its login form, is not mistaken for one.
+const blockPageMaxBytes = 64 << 10
+
+// blockSignatures recognize the block, CAPTCHA and consent pages of common
+// bot protections. The first match wins.
+var blockSignatures = []struct {
+	err error
+	re  *regexp.Regexp
+}{
+	// Amazon'un robot kontrol sayfası
+	{ErrCaptcha, regexp.MustCompile(`/errors/validateCaptcha|(?i)<title>\s*robot check`)},
+	// reCAPTCHA, hCaptcha, Cloudflare Turnstile, DataDome ve PerimeterX
+	{ErrCaptcha, regexp.MustCompile(`(?i)g-recaptcha|h-captcha|cf-turnstile|captcha-delivery\.com|px-captcha`)},
+	// Cloudflare'in tarayıcı doğrulaması
+	{ErrCaptcha, regexp.MustCompile(`(?i)<title>\s*(just a moment|attention required)`)},
+	// Akamai ve Imperva (Incapsula) engelleme sayfaları
+	{ErrBlocked, regexp.MustCompile(`(?i)<title>\s*access denied\s*</title>|incapsula incident id|request unsuccessful\. incapsula`)},
+	{ErrConsentWall, regexp.MustCompile(`(?i)<title>[^<]*(çerez|cookie|consent)[^<]*</title>`)},
+}
+
+// consentPath and loginPath match the paths of consent and login pages a
+// store may redirect to.
+var (
+	consentPath = regexp.MustCompile(`(?i)/(cookie-?)?consent`)
+	loginPath   = regexp.MustCompile(`(?i)/(login|signin|giris|uye-girisi|ap/signin)(/|$)`)
+)
+
+// blockTransport turns the block pages, CAPTCHAs, consent walls and
+// unexpected redirects a store answers with into errors, so they are not
+// mistaken for empty result pages. domain is the domain of the site being
+// scraped, redirects to other hosts are unexpected.
+type blockTransport struct {
+	next   http.RoundTripper
+	domain string
+}
+
+func (t *blockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		loc, err := resp.Location()
+		if err != nil {
+			return resp, nil
+		}
+		if err := t.checkRedirect(req.URL, loc); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if isHTML(resp) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, blockPageMaxBytes+1))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if len(body) <= blockPageMaxBytes {
+			for _, sig := range blockSignatures {
+				if sig.re.Match(body) {
+					resp.Body.Close()
+					return nil, fmt.Errorf("%w: %s", sig.err, req.URL)
+				}
+			}
+		}
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusUnavailableForLegalReasons:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s answered HTTP %d", ErrBlocked, req.URL.Host, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// checkRedirect returns an error if a redirect from src to dst leaves the
+// site or leads to a consent or login page.
+func (t *blockTransport) checkRedirect(src, dst *url.URL) error {
+	host := strings.ToLower(dst.Hostname())
+	switch {
+	case strings.HasPrefix(host, "consent.") || consentPath.MatchString(dst.Path):
+		return fmt.Errorf("%w: %s redirected to %s", ErrConsentWall, src, dst)
+	case loginPath.MatchString(dst.Path):
+		return fmt.Errorf("%w: %s redirected to the login page %s", ErrUnexpectedRedirect, src, dst)
+	case t.domain != "" && !onSite(host, t.domain):
+		return fmt.Errorf("%w: %s redirected to %s", ErrUnexpectedRedirect, src, dst)
+	}
+	return nil
+}
+
+// siteDomain returns the domain of a site host without its "www." prefix,
+// e.g. "trendyol.com" for "www.trendyol.com".
+func siteDomain(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// onSite reports whether host is domain, as returned by siteDomain, or one
+// of its subdomains, e.g. "m.trendyol.com" for "trendyol.com".
+func onSite(host, domain string) bool {
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// isHTML reports whether resp carries an HTML document.
+func isHTML(resp *http.Response) bool {
+	contentType := resp.Header.Get("Content-Type")
+	return contentType == "" || strings.Contains(contentType, "html")
+}
+
+// readCloser combines a reader with the closer of another body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isBlockError reports whether err means the store answered with something
+// other than the requested page. Retrying such requests does not help.
+func isBlockError(err error) bool {
+	return errors.Is(err, ErrBlocked) || errors.Is(err, ErrCaptcha) ||
+		errors.Is(err, ErrConsentWall) || errors.Is(err, ErrUnexpectedRedirect)
+}
@@ -0,0 +1,166 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states reported by BreakerState.State.
+const (
+	// BreakerClosed lets every scrape through.
+	BreakerClosed = "closed"
+	// BreakerOpen rejects scrapes until the cool-down has passed.
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single trial scrape through to decide whether
+	// the site has recovered.
+	BreakerHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned for scrapes of a site whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+var (
+	// breakerThreshold is the number of consecutive failed scrapes after
+	// which a site's breaker opens.
+	breakerThreshold = 5
+	// breakerCooldown is how long an open breaker rejects scrapes.
+	breakerCooldown = 30 * time.Second
+	// now returns the current time, tests replace it.
+	now = time.Now
+)
+
+// BreakerState describes the circuit breaker of a site.
+type BreakerState struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// circuitBreaker stops scraping a site that keeps failing for a cool-down
+// period, so a broken store does not hold up every request.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a scrape of the site may start, returning an error
+// wrapping ErrCircuitOpen if not.
+func (b *circuitBreaker) allow(site string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		retryAt := b.openedAt.Add(breakerCooldown)
+		if now().Before(retryAt) {
+			return fmt.Errorf("%w for %s until %s", ErrCircuitOpen, site, retryAt.Format(time.RFC3339))
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w for %s, waiting for a trial scrape", ErrCircuitOpen, site)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a scrape. Scrapes abandoned
+// by the caller say nothing about the site and are not counted.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err == nil:
+		b.state = BreakerClosed
+		b.failures = 0
+	default:
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= breakerThreshold {
+			b.state = BreakerOpen
+			b.openedAt = now()
+		}
+	}
+}
+
+// snapshot returns the current state of the breaker.
+func (b *circuitBreaker) snapshot() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := BreakerState{State: b.state, ConsecutiveFailures: b.failures}
+	if state.State == "" {
+		state.State = BreakerClosed
+	}
+	if b.state != BreakerClosed && !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(breakerCooldown)
+		state.OpenedAt = &openedAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
+
+// breakerPool holds the circuit breaker of every site.
+type breakerPool struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+var breakers = &breakerPool{breakers: make(map[string]*circuitBreaker)}
+
+// get returns the breaker of site.
+func (p *breakerPool) get(site string) *circuitBreaker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.breakers[site]
+	if !ok {
+		b = &circuitBreaker{state: BreakerClosed}
+		p.breakers[site] = b
+	}
+	return b
+}
+
+// Breaker returns the state of the circuit breaker of site.
+func Breaker(site string) BreakerState {
+	return breakers.get(site).snapshot()
+}
+
+// detailBreaker returns the key of the breaker guarding the detail pages of
+// site. Detail pages fail on their own, e.g. for delisted products, and
+// must not stop the searches of the site.
+func detailBreaker(site string) string {
+	return site + ":detail"
+}
+
+// DetailBreaker returns the state of the circuit breaker guarding the detail
+// pages of site.
+func DetailBreaker(site string) BreakerState {
+	return Breaker(detailBreaker(site))
+}
+
+// withBreaker runs scrape unless the circuit breaker of site is open and
+// records its outcome in the breaker.
+func withBreaker[T any](site string, scrape func() (T, error)) (T, error) {
+	b := breakers.get(site)
+	if err := b.allow(site); err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err := scrape()
+	b.record(err)
+	return result, err
+}
@@ -0,0 +1,78 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gocolly/colly"
+)
+
+// baseTransport performs the HTTP requests of every collector. It is the
+// proxy pool once proxies are configured. Tests replace it to serve saved
+// pages instead of hitting the live stores.
+var baseTransport http.RoundTripper = http.DefaultTransport
+
+// newCollector creates a colly collector bound to ctx. No request is started
+// once ctx is done and requests that are in flight are cancelled with it.
+// Requests follow the rate limit and robots.txt setting of the site of diag
+// and transient failures are retried. Block pages, CAPTCHAs, consent walls
+// and unexpected redirects fail the request with the matching error. Every
+// response and retry is recorded in diag.
+//
+// Each collector sends the headers of a browser profile picked at random and
+// shares the cookie jar of its site with the other scrapes of the site.
+func newCollector(ctx context.Context, diag *Diagnostics, options ...func(*colly.Collector)) *colly.Collector {
+	cfg, _ := siteConfig(diag.Site)
+	site, _ := LookupSite(diag.Site)
+	c := colly.NewCollector(options...)
+	randomHeaderProfile().apply(c)
+	c.SetCookieJar(cookieJar(diag.Site))
+	c.WithTransport(&contextTransport{ctx: ctx, base: &retryTransport{
+		next: &blockTransport{
+			next: &politeTransport{
+				next:   sharedTransport(),
+				limit:  cfg.rateLimit(),
+				robots: cfg.RespectRobotsTxt,
+			},
+			domain: siteDomain(site.Domain),
+		},
+		onRetry: diag.retried,
+	}})
+
+	c.OnRequest(func(r *colly.Request) {
+		if ctx.Err() != nil {
+			r.Abort()
+		}
+	})
+	c.OnResponse(func(r *colly.Response) {
+		diag.response(r.StatusCode, len(r.Body))
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		if r != nil {
+			diag.response(r.StatusCode, len(r.Body))
+		}
+	})
+
+	return c
+}
+
+// visit visits url with c and reports ctx's error if the context was done
+// before or during the visit, since colly silently drops aborted requests.
+func visit(ctx context.Context, c *colly.Collector, url string) error {
+	err := c.Visit(url)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// contextTransport attaches a context to every outgoing request so that the
+// underlying connection is torn down when the context is cancelled.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
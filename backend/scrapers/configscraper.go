@@ -0,0 +1,422 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"smartyshop/internal"
+	"smartyshop/pkg/utils"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// ConfigScraper scrapes a registered site using the SiteConfig currently in
+// effect for it, so selector changes apply without a rebuild. When the
+// selectors yield no products, the schema.org data embedded in the result
+// pages is used instead.
+type ConfigScraper struct {
+	SiteID string
+}
+
+// Scrape scrapes the site's search results for query, following result
+// pages as allowed by opts. Sites configured with StrategyAPI are scraped
+// from their JSON search API first and from their result pages if it fails.
+func (s *ConfigScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return withBreaker(s.SiteID, func() ([]internal.Product, error) {
+		return s.scrape(ctx, query, opts)
+	})
+}
+
+// scrape implements Scrape.
+func (s *ConfigScraper) scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	cfg, _ := siteConfig(s.SiteID)
+	fallback := false
+	if cfg.Strategy == StrategyAPI {
+		diag := newDiagnostics(s.SiteID, query)
+		products, err := scrapeAPI(ctx, s.SiteID, query, opts, diag)
+		if err == nil && len(products) > 0 {
+			for _, product := range products {
+				diag.checkProduct(product)
+			}
+			diag.finish(len(products), nil)
+			return products, nil
+		}
+		if ctx.Err() != nil {
+			diag.finish(0, ctx.Err())
+			return nil, ctx.Err()
+		}
+		log.Printf("Warning: %s search API yielded no products (err: %v), scraping the result pages", s.SiteID, err)
+		fallback = true
+	}
+
+	diag := newDiagnostics(s.SiteID, query)
+	if fallback {
+		diag.fellBack()
+	}
+	return scrapeSearch(ctx, s.SiteID, query, opts, true, diag)
+}
+
+// scrapeSearch scrapes the search results of a site for query, recording the
+// scrape in diag. Products are read with the site's card selectors when
+// useSelectors is set and the site has any, falling back to the structured
+// data of the pages if they yield none.
+func scrapeSearch(ctx context.Context, siteID, query string, opts Options, useSelectors bool, diag *Diagnostics) ([]internal.Product, error) {
+	site, ok := LookupSite(siteID)
+	if !ok {
+		return nil, fmt.Errorf("unknown site %q", siteID)
+	}
+	cfg, ok := siteConfig(siteID)
+	if !ok {
+		return nil, fmt.Errorf("no selector config for site %q", siteID)
+	}
+	useSelectors = useSelectors && cfg.Card != ""
+
+	selected := newResultSet(site.Name, opts)
+	structured := newResultSet(site.Name, opts)
+	found := 0
+
+	c := newCollector(ctx, diag,
+		colly.AllowedDomains(site.Domain),
+	)
+	if cfg.UserAgent != "" {
+		c.UserAgent = cfg.UserAgent
+	}
+
+	if useSelectors {
+		c.OnHTML(cfg.Card, func(e *colly.HTMLElement) {
+			diag.cardMatched()
+			found++
+
+			if selected.full() {
+				return
+			}
+			product, ok := cfg.extractProduct(e, diag)
+			if !ok {
+				return
+			}
+			selected.add(product)
+		})
+	}
+
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		// Structured data is only needed while the selectors found nothing.
+		if useSelectors && len(selected.products) > 0 {
+			return
+		}
+		for _, product := range structuredProducts(e.DOM) {
+			if !useSelectors {
+				diag.cardMatched()
+				found++
+			}
+			if product.Title == "" {
+				continue
+			}
+			resolveURLs(e.Request, &product)
+			structured.add(product)
+		}
+	})
+
+	c.OnRequest(func(r *colly.Request) {
+		log.Println("Visiting", r.URL.String())
+	})
+
+	primary := structured
+	if useSelectors {
+		primary = selected
+	}
+
+	err := visitPages(ctx, c, site.ID, cfg.pages(opts), func(page int) string {
+		return cfg.pageURL(query, page)
+	}, &found, primary.full)
+
+	products := primary.products
+	diag.setSource(SourceSelectors)
+	if !useSelectors {
+		diag.setSource(SourceStructuredData)
+	} else if len(products) == 0 && len(structured.products) > 0 {
+		log.Printf("Warning: no products matched the %s selectors, using structured data", site.ID)
+		products = structured.products
+		diag.setSource(SourceStructuredData)
+		diag.fellBack()
+	}
+	// Cards that match but cannot be read mean the markup changed, an
+	// empty result page matches none.
+	if err == nil && len(products) == 0 && found > 0 {
+		err = fmt.Errorf("%w: %d product cards matched on %s but none could be extracted", ErrLayoutChanged, found, site.Name)
+	}
+
+	for _, product := range products {
+		diag.checkProduct(product)
+	}
+	diag.finish(len(products), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// visitPages visits up to n result pages with c, the URL of each zero-based
+// page given by pageURL. It stops after a page that does not increase *found
+// or once done reports true. Products from earlier pages are kept when a
+// later page fails, so only the error of the first page is returned, unless
+// the scrape itself was abandoned.
+func visitPages(ctx context.Context, c *colly.Collector, siteID string, n int, pageURL func(page int) string, found *int, done func() bool) error {
+	for page := 0; page < n; page++ {
+		before := *found
+		if err := visit(ctx, c, pageURL(page)); err != nil {
+			if page == 0 || ctx.Err() != nil {
+				return err
+			}
+			log.Printf("Warning: stopping pagination of %s at page %d: %v", siteID, page+1, err)
+			return nil
+		}
+		if *found == before || done() {
+			return nil
+		}
+	}
+	return nil
+}
+
+// resultSet collects the products of a scrape over its result pages.
+type resultSet struct {
+	site     string
+	opts     Options
+	products []internal.Product
+	seen     map[string]bool
+}
+
+// newResultSet returns an empty result set for the products of site.
+func newResultSet(site string, opts Options) *resultSet {
+	return &resultSet{site: site, opts: opts, seen: make(map[string]bool)}
+}
+
+// full reports whether the set holds as many products as opts allow.
+func (r *resultSet) full() bool {
+	return r.opts.full(len(r.products))
+}
+
+// add appends product unless the set is full. Result pages may overlap, only
+// the first occurrence of a product is kept.
+func (r *resultSet) add(product internal.Product) {
+	if r.full() {
+		return
+	}
+	if product.URL != "" {
+		if r.seen[product.URL] {
+			return
+		}
+		r.seen[product.URL] = true
+	}
+	product.Site = r.site
+	r.products = append(r.products, product)
+}
+
+// resolveURLs makes the product and image URLs of a structured data product
+// absolute.
+func resolveURLs(req *colly.Request, product *internal.Product) {
+	if product.URL != "" {
+		product.URL = req.AbsoluteURL(product.URL)
+	}
+	for i, src := range product.Images {
+		product.Images[i] = req.AbsoluteURL(src)
+	}
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0]
+	}
+}
+
+// pages returns the number of result pages to visit for opts.
+func (cfg SiteConfig) pages(opts Options) int {
+	if cfg.PageParam == "" {
+		return 1
+	}
+	return opts.pages()
+}
+
+// searchURL returns the search page URL for query.
+func (cfg SiteConfig) searchURL(query string) string {
+	if cfg.QueryEncoding == QueryEnglish {
+		query = utils.ConvertToEnglishChars(query)
+	}
+	escaped := url.QueryEscape(query)
+	if i := strings.Index(cfg.SearchURL, "{query}"); !strings.Contains(cfg.SearchURL[:i+1], "?") {
+		escaped = url.PathEscape(query)
+	}
+	return strings.ReplaceAll(cfg.SearchURL, "{query}", escaped)
+}
+
+// pageURL returns the URL of the zero-based result page for query. The
+// first page is the plain search URL.
+func (cfg SiteConfig) pageURL(query string, page int) string {
+	searchURL := cfg.searchURL(query)
+	if page == 0 || cfg.PageParam == "" {
+		return searchURL
+	}
+
+	first := 1
+	if cfg.FirstPage != nil {
+		first = *cfg.FirstPage
+	}
+
+	sep := "?"
+	if strings.Contains(searchURL, "?") {
+		sep = "&"
+	}
+	return searchURL + sep + cfg.PageParam + "=" + strconv.Itoa(first+page)
+}
+
+// extractProduct reads a product from a card. It reports false, after
+// recording the missing field, if a required field is empty.
+func (cfg SiteConfig) extractProduct(e *colly.HTMLElement, diag *Diagnostics) (internal.Product, bool) {
+	values := make(map[string]string, len(cfg.Fields))
+	for field, rules := range cfg.Fields {
+		values[field] = extractField(e.DOM, rules)
+	}
+
+	for _, field := range cfg.Required {
+		if values[field] == "" {
+			log.Printf("Warning: card without %s skipped", field)
+			diag.missingField(field)
+			return internal.Product{}, false
+		}
+	}
+
+	product := internal.Product{
+		Title:        values[FieldTitle],
+		Price:        internal.ParsePrice(values[FieldPrice]),
+		ReviewsCount: parseCount(values[FieldReviews]),
+		Description:  values[FieldDescription],
+		Brand:        values[FieldBrand],
+	}
+	if href := values[FieldURL]; href != "" {
+		product.URL = e.Request.AbsoluteURL(href)
+	}
+	if src := values[FieldImage]; src != "" {
+		product.ImageURL = e.Request.AbsoluteURL(src)
+	}
+	product.SetScrapedRating(parseRating(values[FieldRating]))
+
+	return product, true
+}
+
+// extractField returns the first non-empty value produced by rules within sel.
+func extractField(sel *goquery.Selection, rules []FieldRule) string {
+	for _, rule := range rules {
+		if value := rule.extract(sel); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// extract returns the first non-empty value the rule yields within card.
+func (r FieldRule) extract(card *goquery.Selection) string {
+	selection := card
+	if r.Selector != "" {
+		selection = card.Find(r.Selector)
+	}
+	if len(r.Join) > 0 {
+		selection = selection.First()
+	}
+
+	value := ""
+	selection.EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		value = r.clean(r.read(s))
+		return value == ""
+	})
+	return value
+}
+
+// extractAll returns every non-empty value the rule yields within sel.
+func (r FieldRule) extractAll(sel *goquery.Selection) []string {
+	selection := sel
+	if r.Selector != "" {
+		selection = sel.Find(r.Selector)
+	}
+
+	var values []string
+	selection.Each(func(_ int, s *goquery.Selection) {
+		if value := r.clean(r.read(s)); value != "" {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+// read returns the raw text or attribute value of an element, or its joined
+// parts.
+func (r FieldRule) read(s *goquery.Selection) string {
+	if len(r.Join) > 0 {
+		parts := make([]string, len(r.Join))
+		for i, part := range r.Join {
+			if parts[i] = part.extract(s); parts[i] == "" {
+				return ""
+			}
+		}
+		return strings.Join(parts, r.Separator)
+	}
+	if r.Attr != "" {
+		value, _ := s.Attr(r.Attr)
+		return value
+	}
+	return s.Text()
+}
+
+// clean applies the rule's cleanup steps to a raw value.
+func (r FieldRule) clean(raw string) string {
+	value := strings.Join(strings.Fields(raw), " ")
+	if r.Trim != "" {
+		value = strings.TrimSpace(strings.Trim(value, r.Trim))
+	}
+	if r.re != nil {
+		match := r.re.FindStringSubmatch(value)
+		switch {
+		case match == nil:
+			return ""
+		case len(match) > 1:
+			value = match[1]
+		default:
+			value = match[0]
+		}
+	}
+	if value != "" && r.OutOf > 0 {
+		n, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return ""
+		}
+		value = strconv.FormatFloat(n*5/r.OutOf, 'f', -1, 64)
+	}
+	if value != "" && r.Format != "" {
+		value = strings.ReplaceAll(r.Format, "{value}", value)
+	}
+	return value
+}
+
+// parseRating parses ratings such as "4.5" and "4,5", returning 0 if none is found.
+func parseRating(s string) float64 {
+	rating, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return rating
+}
+
+// parseCount parses counts such as "1.234" or "(87)", returning 0 if none is found.
+func parseCount(s string) int {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	count, err := strconv.Atoi(digits.String())
+	if err != nil {
+		return 0
+	}
+	return count
+}
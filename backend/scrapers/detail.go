@@ -0,0 +1,184 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"smartyshop/internal"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// enrichWorkers bounds the number of detail pages Enrich fetches at once.
+const enrichWorkers = 4
+
+// DetailScraper is implemented by scrapers that can read product detail pages.
+type DetailScraper interface {
+	Detail(ctx context.Context, productURL string) (internal.Product, error)
+}
+
+// Detail scrapes the product detail page at productURL. Values read with the
+// site's detail selectors take precedence over the structured data embedded
+// in the page, which fills the remaining gaps.
+func (s *ConfigScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return withBreaker(detailBreaker(s.SiteID), func() (internal.Product, error) {
+		return s.detail(ctx, productURL)
+	})
+}
+
+// detail implements Detail.
+func (s *ConfigScraper) detail(ctx context.Context, productURL string) (internal.Product, error) {
+	site, ok := LookupSite(s.SiteID)
+	if !ok {
+		return internal.Product{}, fmt.Errorf("unknown site %q", s.SiteID)
+	}
+	cfg, ok := siteConfig(s.SiteID)
+	if !ok {
+		return internal.Product{}, fmt.Errorf("no selector config for site %q", s.SiteID)
+	}
+
+	// Product pages may be linked on another subdomain of the site, e.g.
+	// its mobile site.
+	domains := []string{site.Domain}
+	if u, err := url.Parse(productURL); err == nil && onSite(u.Hostname(), siteDomain(site.Domain)) {
+		domains = append(domains, u.Hostname())
+	}
+
+	// Detail pages are deliberately not recorded in the site health, which
+	// tracks how well the search result selectors match.
+	diag := newDiagnostics(site.ID, productURL)
+	c := newCollector(ctx, diag,
+		colly.AllowedDomains(domains...),
+	)
+	if cfg.UserAgent != "" {
+		c.UserAgent = cfg.UserAgent
+	}
+
+	var product internal.Product
+	found := false
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		found = true
+		if cfg.Detail != nil {
+			product = cfg.Detail.extract(e)
+		} else {
+			product.SetScrapedRating(0)
+		}
+		if structured := structuredProducts(e.DOM); len(structured) > 0 {
+			product.MergeDetail(structured[0])
+		}
+	})
+
+	if err := visit(ctx, c, productURL); err != nil {
+		return internal.Product{}, err
+	}
+	if !found {
+		return internal.Product{}, fmt.Errorf("no HTML document at %s", productURL)
+	}
+	if product.Title == "" {
+		return internal.Product{}, fmt.Errorf("%w: no product title found at %s", ErrLayoutChanged, productURL)
+	}
+
+	product.URL = productURL
+	product.Site = site.Name
+	return product, nil
+}
+
+// extract reads a product from a detail page.
+func (d DetailConfig) extract(e *colly.HTMLElement) internal.Product {
+	values := make(map[string]string, len(d.Fields))
+	for field, rules := range d.Fields {
+		values[field] = extractField(e.DOM, rules)
+	}
+
+	product := internal.Product{
+		Title:        values[FieldTitle],
+		Price:        internal.ParsePrice(values[FieldPrice]),
+		ReviewsCount: parseCount(values[FieldReviews]),
+		Description:  values[FieldDescription],
+		Brand:        values[FieldBrand],
+		Seller:       values[FieldSeller],
+	}
+	product.SetScrapedRating(parseRating(values[FieldRating]))
+
+	if stock := values[FieldStock]; stock != "" {
+		inStock := d.outOfStock == nil || !d.outOfStock.MatchString(stock)
+		product.InStock = &inStock
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range d.Images {
+		for _, src := range rule.extractAll(e.DOM) {
+			src = e.Request.AbsoluteURL(src)
+			if src != "" && !seen[src] {
+				seen[src] = true
+				product.Images = append(product.Images, src)
+			}
+		}
+	}
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0]
+	}
+
+	if d.SpecRows != "" {
+		specs := make(map[string]string)
+		e.DOM.Find(d.SpecRows).Each(func(_ int, row *goquery.Selection) {
+			key := strings.TrimSuffix(extractField(row, d.SpecKey), ":")
+			value := extractField(row, d.SpecValue)
+			if key != "" && value != "" {
+				specs[key] = value
+			}
+		})
+		if len(specs) > 0 {
+			product.Specs = specs
+		}
+	}
+
+	return product
+}
+
+// Enrich fetches the detail pages of the first max products and returns a
+// copy of products with the details merged in. Products of sites that cannot
+// read detail pages, or whose page fails to load, are returned unchanged.
+func Enrich(ctx context.Context, products []internal.Product, max int) []internal.Product {
+	enriched := make([]internal.Product, len(products))
+	copy(enriched, products)
+	if max > len(enriched) {
+		max = len(enriched)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < enrichWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				site, ok := LookupURL(enriched[i].URL)
+				if !ok {
+					continue
+				}
+				scraper, _ := Lookup(site.ID)
+				detailScraper, ok := scraper.(DetailScraper)
+				if !ok {
+					continue
+				}
+				detail, err := detailScraper.Detail(ctx, enriched[i].URL)
+				if err != nil {
+					continue
+				}
+				enriched[i].MergeDetail(detail)
+			}
+		}()
+	}
+
+	for i := 0; i < max && ctx.Err() == nil; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return enriched
+}
@@ -0,0 +1,183 @@
+package scrapers
+
+import (
+	"smartyshop/internal"
+	"sync"
+	"time"
+)
+
+// Product fields extracted by the scrapers. FieldTitle to FieldReviews are
+// tracked by Diagnostics.MissingFields.
+const (
+	FieldTitle       = "title"
+	FieldPrice       = "price"
+	FieldURL         = "url"
+	FieldImage       = "image"
+	FieldRating      = "rating"
+	FieldReviews     = "reviews"
+	FieldDescription = "description"
+	FieldBrand       = "brand"
+	FieldSeller      = "seller"
+	FieldStock       = "stock"
+)
+
+// Sources of the products of a scrape, reported by Diagnostics.Source.
+const (
+	// SourceSelectors means the products were read with the site's card
+	// and field selectors.
+	SourceSelectors = "selectors"
+	// SourceStructuredData means the products were read from the schema.org
+	// JSON-LD or microdata embedded in the result pages.
+	SourceStructuredData = "structured_data"
+	// SourceAPI means the products were read from the store's JSON search
+	// API.
+	SourceAPI = "api"
+)
+
+// Diagnostics describes a single scrape of a site: what the store sent back
+// and how well the selectors matched it.
+type Diagnostics struct {
+	Site          string         `json:"site"`
+	Query         string         `json:"query"`
+	StartedAt     time.Time      `json:"started_at"`
+	DurationMs    int64          `json:"duration_ms"`
+	HTTPStatus    int            `json:"http_status"`
+	BytesReceived int            `json:"bytes_received"`
+	Retries       int            `json:"retries"`
+	CardsMatched  int            `json:"cards_matched"`
+	Products      int            `json:"products"`
+	MissingFields map[string]int `json:"missing_fields"`
+	Source        string         `json:"source,omitempty"`
+	// Fallback is set when the site's primary source yielded no products
+	// and Source was used instead.
+	Fallback bool   `json:"fallback,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// ErrorKind classifies Error, see ErrorKind.
+	ErrorKind string `json:"error_kind,omitempty"`
+
+	mu sync.Mutex
+}
+
+// newDiagnostics starts the diagnostics of a scrape of site for query.
+func newDiagnostics(site, query string) *Diagnostics {
+	return &Diagnostics{
+		Site:          site,
+		Query:         query,
+		StartedAt:     time.Now(),
+		MissingFields: make(map[string]int),
+	}
+}
+
+// response records the status code and body size of a page fetched by the scrape.
+func (d *Diagnostics) response(status, bytes int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.HTTPStatus = status
+	d.BytesReceived += bytes
+}
+
+// retried records that a request of the scrape was retried.
+func (d *Diagnostics) retried() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Retries++
+}
+
+// cardMatched records that the card selector matched an element.
+func (d *Diagnostics) cardMatched() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.CardsMatched++
+}
+
+// missingField records that field could not be extracted from a card.
+func (d *Diagnostics) missingField(field string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.MissingFields[field]++
+}
+
+// setSource records where the products of the scrape were read from.
+func (d *Diagnostics) setSource(source string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Source = source
+}
+
+// fellBack records that the primary source of the site yielded nothing.
+func (d *Diagnostics) fellBack() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Fallback = true
+}
+
+// checkProduct records every tracked field that is empty in p.
+func (d *Diagnostics) checkProduct(p internal.Product) {
+	if p.Title == "" {
+		d.missingField(FieldTitle)
+	}
+	if p.Price.IsZero() {
+		d.missingField(FieldPrice)
+	}
+	if p.URL == "" {
+		d.missingField(FieldURL)
+	}
+	if p.ImageURL == "" {
+		d.missingField(FieldImage)
+	}
+	if !p.HasRating() {
+		d.missingField(FieldRating)
+	}
+	if p.ReviewsCount == 0 {
+		d.missingField(FieldReviews)
+	}
+}
+
+// finish completes the diagnostics with the outcome of the scrape and
+// records them in the site health monitor.
+func (d *Diagnostics) finish(products int, err error) {
+	d.mu.Lock()
+	d.DurationMs = time.Since(d.StartedAt).Milliseconds()
+	d.Products = products
+	if err != nil {
+		d.Error = err.Error()
+		d.ErrorKind = ErrorKind(err)
+	}
+	d.mu.Unlock()
+
+	health.record(d)
+}
+
+// snapshot returns a copy of d that is safe to hand out.
+func (d *Diagnostics) snapshot() *Diagnostics {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	missing := make(map[string]int, len(d.MissingFields))
+	for field, n := range d.MissingFields {
+		missing[field] = n
+	}
+
+	return &Diagnostics{
+		Site:          d.Site,
+		Query:         d.Query,
+		StartedAt:     d.StartedAt,
+		DurationMs:    d.DurationMs,
+		HTTPStatus:    d.HTTPStatus,
+		BytesReceived: d.BytesReceived,
+		Retries:       d.Retries,
+		CardsMatched:  d.CardsMatched,
+		Products:      d.Products,
+		MissingFields: missing,
+		Source:        d.Source,
+		Fallback:      d.Fallback,
+		Error:         d.Error,
+		ErrorKind:     d.ErrorKind,
+	}
+}
@@ -0,0 +1,163 @@
+package scrapers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Site health statuses reported by HealthReport.
+const (
+	HealthUnknown  = "unknown"
+	HealthHealthy  = "healthy"
+	HealthDegraded = "degraded"
+	HealthFailing  = "failing"
+)
+
+const (
+	// healthHistorySize is the number of recent scrapes kept per site.
+	healthHistorySize = 20
+	// emptyScrapesThreshold is the number of consecutive scrapes without a
+	// single matched card after which a site is considered degraded.
+	emptyScrapesThreshold = 3
+	// missingFieldRatio is the share of cards missing an essential field
+	// above which a site is considered degraded.
+	missingFieldRatio = 0.5
+)
+
+// essentialFields must be present on most cards of a healthy site.
+var essentialFields = []string{FieldTitle, FieldPrice, FieldURL}
+
+// SiteHealth summarizes the recent scrapes of a site.
+type SiteHealth struct {
+	Site     string   `json:"site"`
+	Status   string   `json:"status"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Scrapes  int      `json:"scrapes"`
+	Failures int      `json:"failures"`
+	// Errors counts the failed scrapes by error kind, e.g. how often the
+	// site answered with a CAPTCHA.
+	Errors      map[string]int `json:"errors,omitempty"`
+	LastSuccess *time.Time     `json:"last_success,omitempty"`
+	Last        *Diagnostics   `json:"last,omitempty"`
+	Breaker     BreakerState   `json:"breaker"`
+}
+
+// healthMonitor keeps the diagnostics of the most recent scrapes per site.
+type healthMonitor struct {
+	mu      sync.Mutex
+	history map[string][]*Diagnostics
+}
+
+var health = &healthMonitor{history: make(map[string][]*Diagnostics)}
+
+// record adds d to the history of its site.
+func (m *healthMonitor) record(d *Diagnostics) {
+	snapshot := d.snapshot()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := append(m.history[snapshot.Site], snapshot)
+	if len(history) > healthHistorySize {
+		history = history[len(history)-healthHistorySize:]
+	}
+	m.history[snapshot.Site] = history
+}
+
+// report evaluates the health of site from its recorded history.
+func (m *healthMonitor) report(site string) SiteHealth {
+	m.mu.Lock()
+	history := append([]*Diagnostics(nil), m.history[site]...)
+	m.mu.Unlock()
+
+	h := evaluateHealth(site, history)
+	h.Breaker = Breaker(site)
+	if h.Breaker.State == BreakerOpen {
+		h.Status = HealthFailing
+		h.Reasons = append(h.Reasons, fmt.Sprintf("circuit breaker open after %d consecutive failures", h.Breaker.ConsecutiveFailures))
+	}
+	return h
+}
+
+// HealthReport returns the health of every registered site.
+func HealthReport() []SiteHealth {
+	sites := Sites()
+	report := make([]SiteHealth, len(sites))
+	for i, site := range sites {
+		report[i] = health.report(site.ID)
+	}
+	return report
+}
+
+// evaluateHealth derives the health of a site from its recent diagnostics,
+// oldest first.
+func evaluateHealth(site string, history []*Diagnostics) SiteHealth {
+	h := SiteHealth{Site: site, Status: HealthUnknown, Scrapes: len(history)}
+	if len(history) == 0 {
+		return h
+	}
+
+	for _, d := range history {
+		if d.Error != "" {
+			h.Failures++
+			if h.Errors == nil {
+				h.Errors = make(map[string]int)
+			}
+			kind := d.ErrorKind
+			if kind == "" {
+				kind = ErrorKindOther
+			}
+			h.Errors[kind]++
+		} else {
+			startedAt := d.StartedAt
+			h.LastSuccess = &startedAt
+		}
+	}
+
+	last := history[len(history)-1]
+	h.Last = last
+	h.Status = HealthHealthy
+
+	if last.Error != "" {
+		h.Status = HealthFailing
+		h.Reasons = append(h.Reasons, "last scrape failed: "+last.Error)
+		return h
+	}
+	if last.HTTPStatus >= 400 {
+		h.Status = HealthFailing
+		h.Reasons = append(h.Reasons, fmt.Sprintf("last scrape received HTTP %d", last.HTTPStatus))
+		return h
+	}
+
+	if last.CardsMatched > 0 {
+		for _, field := range essentialFields {
+			missing := last.MissingFields[field]
+			if float64(missing)/float64(last.CardsMatched) > missingFieldRatio {
+				h.Reasons = append(h.Reasons, fmt.Sprintf("%s missing on %d of %d cards", field, missing, last.CardsMatched))
+			}
+		}
+	}
+
+	if last.Fallback {
+		h.Reasons = append(h.Reasons, fmt.Sprintf("no products matched the primary source, served from %s", last.Source))
+	}
+
+	if len(history) >= emptyScrapesThreshold {
+		empty := true
+		for _, d := range history[len(history)-emptyScrapesThreshold:] {
+			if d.Error != "" || d.CardsMatched > 0 || d.BytesReceived == 0 {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			h.Reasons = append(h.Reasons, fmt.Sprintf("no product cards matched in the last %d scrapes", emptyScrapesThreshold))
+		}
+	}
+
+	if len(h.Reasons) > 0 {
+		h.Status = HealthDegraded
+	}
+	return h
+}
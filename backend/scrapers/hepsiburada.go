@@ -0,0 +1,50 @@
+package scrapers
+
+import (
+	"context"
+	"smartyshop/internal"
+)
+
+// HepsiburadaScraper implements the Scraper interface for Hepsiburada.
+type HepsiburadaScraper struct{}
+
+func init() {
+	Register(Site{
+		ID:           "hepsiburada",
+		Name:         "Hepsiburada",
+		Domain:       "www.hepsiburada.com",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews},
+	}, func() Scraper { return &HepsiburadaScraper{} })
+
+	// Hepsiburada sınıf adlarına derleme sırasında rastgele ek koyar,
+	// bu yüzden seçiciler data-test-id özniteliklerine dayanır.
+	registerSiteConfig("hepsiburada", SiteConfig{
+		SearchURL: "https://www.hepsiburada.com/ara?q={query}",
+		PageParam: "sayfa",
+		Card:      "li[class*='productListContent']",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {
+				{Selector: "[data-test-id='product-card-name']"},
+				{Selector: "a[title]", Attr: "title"},
+			},
+			// İndirimli ürünlerde önce sepetteki fiyat, sonra güncel fiyat gelir
+			FieldPrice: {
+				{Selector: "[data-test-id='price-current-price']"},
+				{Selector: "[data-test-id='final-price-1']"},
+			},
+			FieldImage: {
+				{Selector: "img", Attr: "data-src"},
+				{Selector: "img", Attr: "src"},
+			},
+			FieldURL:     {{Selector: "a", Attr: "href"}},
+			FieldRating:  {{Selector: "[data-test-id='review-score']"}},
+			FieldReviews: {{Selector: "[data-test-id='review-count']", Trim: "()"}},
+		},
+		Required: []string{FieldTitle, FieldURL},
+	})
+}
+
+// Scrape scrapes Hepsiburada for products.
+func (s *HepsiburadaScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "hepsiburada"}).Scrape(ctx, query, opts)
+}
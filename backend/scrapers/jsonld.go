@@ -0,0 +1,242 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"math"
+	"smartyshop/internal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// structuredProducts returns the schema.org Products described by a
+// document, in document order. JSON-LD is preferred, microdata is only read
+// from documents without JSON-LD products.
+func structuredProducts(doc *goquery.Selection) []internal.Product {
+	if products := jsonLDProducts(doc); len(products) > 0 {
+		return products
+	}
+	return microdataProducts(doc)
+}
+
+// jsonLDProducts returns the schema.org Products described by the JSON-LD
+// scripts of a document, in document order.
+func jsonLDProducts(doc *goquery.Selection) []internal.Product {
+	var products []internal.Product
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return
+		}
+		walkJSONLD(data, func(node map[string]interface{}) {
+			products = append(products, productFromJSONLD(node))
+		})
+	})
+	return products
+}
+
+// walkJSONLD calls fn for every Product node in a JSON-LD document,
+// descending into @graph arrays and nested values. Entries of an ItemList
+// that only carry a name and URL are reported as Products as well.
+func walkJSONLD(v interface{}, fn func(map[string]interface{})) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			walkJSONLD(item, fn)
+		}
+	case map[string]interface{}:
+		if ldTypeIs(v, "Product") {
+			fn(v)
+			return
+		}
+		if ldTypeIs(v, "ItemList") {
+			for _, entry := range ldList(v["itemListElement"]) {
+				walkListEntry(entry, fn)
+			}
+			return
+		}
+		// Map iteration order is random, visit keys in a fixed order so
+		// products are reported in the same order every time.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkJSONLD(v[key], fn)
+		}
+	}
+}
+
+// walkListEntry reports the product of an ItemList entry.
+func walkListEntry(entry interface{}, fn func(map[string]interface{})) {
+	found := false
+	walkJSONLD(entry, func(node map[string]interface{}) {
+		found = true
+		fn(node)
+	})
+	if found {
+		return
+	}
+
+	// {"@type": "ListItem", "position": 1, "name": "...", "url": "..."},
+	// where the URL may also be given as "item".
+	node, ok := entry.(map[string]interface{})
+	if !ok {
+		return
+	}
+	name, url := ldString(node["name"]), ldString(node["url"])
+	if item, ok := node["item"].(string); ok && url == "" {
+		url = item
+	}
+	if name != "" && url != "" {
+		fn(map[string]interface{}{"name": name, "url": url})
+	}
+}
+
+// ldTypeIs reports whether the @type of a JSON-LD node is typ.
+func ldTypeIs(node map[string]interface{}, typ string) bool {
+	switch t := node["@type"].(type) {
+	case string:
+		return ldName(t) == typ
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && ldName(s) == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ldName strips the schema.org prefix from a type or enumeration value, e.g.
+// "https://schema.org/InStock" → "InStock".
+func ldName(s string) string {
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// productFromJSONLD maps a schema.org Product node to a Product.
+func productFromJSONLD(node map[string]interface{}) internal.Product {
+	product := internal.Product{
+		Title:       ldString(node["name"]),
+		URL:         ldString(node["url"]),
+		Description: ldString(node["description"]),
+		Brand:       ldString(node["brand"]),
+		Images:      ldStrings(node["image"]),
+	}
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0]
+	}
+
+	offer := ldFirst(node["offers"])
+	price := ldString(offer["price"])
+	if price == "" {
+		price = ldString(offer["lowPrice"])
+	}
+	if price != "" {
+		product.Price = schemaPrice(price, ldString(offer["priceCurrency"]))
+	}
+	product.Seller = ldString(offer["seller"])
+	if availability := ldString(offer["availability"]); availability != "" {
+		inStock := ldName(availability) == "InStock" || ldName(availability) == "LimitedAvailability"
+		product.InStock = &inStock
+	}
+
+	rating := ldFirst(node["aggregateRating"])
+	product.SetScrapedRating(parseRating(ldString(rating["ratingValue"])))
+	product.ReviewsCount = parseCount(ldString(rating["reviewCount"]))
+	if product.ReviewsCount == 0 {
+		product.ReviewsCount = parseCount(ldString(rating["ratingCount"]))
+	}
+
+	if props, ok := node["additionalProperty"].([]interface{}); ok {
+		product.Specs = make(map[string]string, len(props))
+		for _, prop := range props {
+			p, ok := prop.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, value := ldString(p["name"]), ldString(p["value"]); name != "" && value != "" {
+				product.Specs[name] = value
+			}
+		}
+	}
+
+	return product
+}
+
+// ldList returns v as an array, wrapping a single value.
+func ldList(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+// ldFirst returns v, or its first element if v is an array, as an object.
+func ldFirst(v interface{}) map[string]interface{} {
+	if list, ok := v.([]interface{}); ok {
+		if len(list) == 0 {
+			return nil
+		}
+		v = list[0]
+	}
+	node, _ := v.(map[string]interface{})
+	return node
+}
+
+// schemaPrice parses a schema.org price in currency, a number with a dot
+// as the decimal separator such as "12.500" or "1299.90". Prices written out
+// as displayed, e.g. "1.299,90 TL", are parsed like the visible prices.
+func schemaPrice(value, currency string) internal.Money {
+	if amount, err := strconv.ParseFloat(value, 64); err == nil && amount >= 0 && amount < math.MaxInt64/100 {
+		return internal.NewMoney(amount, currency)
+	}
+	price := internal.ParsePrice(value)
+	if currency != "" {
+		price.Currency = currency
+	}
+	return price
+}
+
+// ldString returns a JSON-LD value as text. Objects such as Brand or
+// Organization are represented by their name, ImageObjects by their URL.
+func ldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		if name := ldString(v["name"]); name != "" {
+			return name
+		}
+		return ldString(v["url"])
+	case []interface{}:
+		if len(v) > 0 {
+			return ldString(v[0])
+		}
+	}
+	return ""
+}
+
+// ldStrings returns a JSON-LD value that may be a single item or an array as
+// a list of strings.
+func ldStrings(v interface{}) []string {
+	var values []string
+	for _, item := range ldList(v) {
+		if s := ldString(item); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -1,100 +1,125 @@
 package scrapers
 
 import (
-	"fmt"
-	"log"
-	"math/rand"
+	"context"
+	"encoding/json"
 	"net/url"
-	"regexp"
 	"smartyshop/internal"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/gocolly/colly"
 )
 
+// MediaMarktScraper implements the Scraper interface for MediaMarkt.
 type MediaMarktScraper struct{}
 
-func (s *MediaMarktScraper) Scrape(query string) ([]internal.Product, error) {
-	var products []internal.Product
-
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.mediamarkt.com.tr"),
-	)
+func init() {
+	Register(Site{
+		ID:           "mediamarkt",
+		Name:         "MediaMarkt",
+		Domain:       "www.mediamarkt.com.tr",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews, CapabilityDetail},
+	}, func() Scraper { return &MediaMarktScraper{} })
+
+	registerSearchAPI("mediamarkt", parseMediaMarktAPI)
+	registerSiteConfig("mediamarkt", SiteConfig{
+		SearchURL: "https://www.mediamarkt.com.tr/tr/search.html?query={query}",
+		PageParam: "page",
+		// Arama sayfası ürünleri bu JSON servisinden yükler
+		Strategy: StrategyAPI,
+		APIURL:   "https://www.mediamarkt.com.tr/api/search/v1/products?query={query}&page={page}",
+		Card:     "div.sc-43f40bb6-0.QXAyC",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {{Selector: "p[data-test='product-title']"}},
+			FieldPrice: {{Selector: "div[data-test='mms-price'] span.sc-5a9f6c31-0.cmEuny"}},
+			FieldImage: {{Selector: "picture img", Attr: "src"}},
+			FieldURL:   {{Selector: "a[data-test='mms-router-link-product-list-item-link']", Attr: "href"}},
+			// "Ortalama ürün değerlendirmesi: 5 yıldız üzerinden 3.5" → "3.5"
+			FieldRating:  {{Selector: "div[data-test='mms-customer-rating']", Attr: "aria-label", Pattern: `üzerinden\s*(\d+(?:[.,]\d+)?)`}},
+			FieldReviews: {{Selector: "span[data-test='mms-customer-rating-count']"}},
+		},
+		Required: []string{FieldURL, FieldTitle},
+		// Ürün sayfası fiyat, stok, marka ve puanı JSON-LD olarak verir,
+		// seçiciler yalnızca orada olmayan bilgileri okur.
+		Detail: &DetailConfig{
+			Fields: map[string][]FieldRule{
+				FieldDescription: {{Selector: "div[data-test='mms-accordion-description']"}},
+			},
+			SpecRows:  "div[data-test='mms-accordion-features'] tr",
+			SpecKey:   []FieldRule{{Selector: "td:first-child"}},
+			SpecValue: []FieldRule{{Selector: "td:last-child"}},
+		},
+	})
+}
+
+// Scrape scrapes MediaMarkt for products.
+func (s *MediaMarktScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "mediamarkt"}).Scrape(ctx, query, opts)
+}
+
+// Detail scrapes a MediaMarkt product page.
+func (s *MediaMarktScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return (&ConfigScraper{SiteID: "mediamarkt"}).Detail(ctx, productURL)
+}
+
+// mediaMarktSearchResponse is the part of a MediaMarkt search API response
+// we use.
+type mediaMarktSearchResponse struct {
+	Products []struct {
+		Title    string `json:"title"`
+		Brand    string `json:"brand"`
+		URL      string `json:"url"`
+		ImageURL string `json:"imageUrl"`
+		Price    struct {
+			Amount       float64 `json:"amount"`
+			StrikeAmount float64 `json:"strikeAmount"`
+			Currency     string  `json:"currency"`
+		} `json:"price"`
+		Rating struct {
+			Average float64 `json:"average"`
+			Count   int     `json:"count"`
+		} `json:"rating"`
+		Seller struct {
+			Name string `json:"name"`
+		} `json:"seller"`
+		Availability string `json:"availability"`
+	} `json:"products"`
+}
+
+// parseMediaMarktAPI maps a MediaMarkt search API response to products.
+func parseMediaMarktAPI(body []byte, base *url.URL) ([]internal.Product, error) {
+	var resp mediaMarktSearchResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
 
-	c.OnHTML("div.sc-43f40bb6-0.QXAyC", func(e *colly.HTMLElement) {
-		// Ürün linki
-		url := e.ChildAttr("a[data-test='mms-router-link-product-list-item-link']", "href")
-		if url == "" {
-			log.Println("Ürün linki boş, atlanıyor")
-			return
+	products := make([]internal.Product, 0, len(resp.Products))
+	for _, item := range resp.Products {
+		product := internal.Product{
+			Title:        item.Title,
+			ImageURL:     item.ImageURL,
+			ReviewsCount: item.Rating.Count,
+			Brand:        item.Brand,
+			Seller:       item.Seller.Name,
 		}
-		fullURL := "https://www.mediamarkt.com.tr" + url
-
-		// Başlık
-		title := e.ChildText("p[data-test='product-title']")
-		if title == "" {
-			log.Println("Başlık boş, atlanıyor")
-			return
+		if ref, err := url.Parse(item.URL); err == nil && item.URL != "" {
+			product.URL = base.ResolveReference(ref).String()
 		}
-
-		// Fiyat
-		price := e.ChildText("div[data-test='mms-price'] span.sc-5a9f6c31-0.cmEuny")
-		price = strings.TrimSpace(price)
-
-		// Rating (puan)
-		ratingText := e.ChildAttr("div[data-test='mms-customer-rating']", "aria-label") // "Ortalama ürün değerlendirmesi: 5 yıldız üzerinden 3.5"
-		rating := 0.0
-		re := regexp.MustCompile(`(\d+(\.\d+)?)`) // Ondalıklı sayı yakalamak için
-		matches := re.FindStringSubmatch(ratingText)
-		if len(matches) > 0 {
-			r, err := strconv.ParseFloat(matches[1], 64)
-			if err == nil {
-				rating = r
-			}
+		if item.Price.Amount > 0 {
+			product.Price = internal.NewMoney(item.Price.Amount, item.Price.Currency)
 		}
-
-		// Eğer rating hala 0 ise, rastgele bir değer ata
-		if rating == 0.0 {
-			rand.Seed(time.Now().UnixNano())
-			rating = 3.5 + rand.Float64()*(4.9-3.5)
-			rating, _ = strconv.ParseFloat(fmt.Sprintf("%.1f", rating), 64) // Tek ondalık basamağa yuvarla
+		if item.Price.StrikeAmount > 0 {
+			product.SetOriginalPrice(internal.NewMoney(item.Price.StrikeAmount, item.Price.Currency))
 		}
-
-		// Yorum sayısı
-		reviewCountStr := e.ChildText("span[data-test='mms-customer-rating-count']")
-		reviewCountStr = strings.TrimSpace(reviewCountStr)
-		reviewCount := 0
-		if reviewCountStr != "" {
-			rc, err := strconv.Atoi(reviewCountStr)
-			if err == nil {
-				reviewCount = rc
-			}
-		}
-
-		// Resim URL'si
-		imageURL := e.ChildAttr("picture img", "src")
-
-		product := internal.Product{
-			Title:        title,
-			Price:        price,
-			URL:          fullURL,
-			ImageURL:     imageURL,
-			Site:         "MediaMarkt",
-			Rating:       rating,
-			ReviewsCount: reviewCount,
+		product.SetScrapedRating(item.Rating.Average)
+
+		switch item.Availability {
+		case "IN_STOCK", "LOW_STOCK":
+			inStock := true
+			product.InStock = &inStock
+		case "OUT_OF_STOCK":
+			inStock := false
+			product.InStock = &inStock
 		}
 
 		products = append(products, product)
-	})
-
-	encodedQuery := url.QueryEscape(query)
-	searchURL := fmt.Sprintf("https://www.mediamarkt.com.tr/tr/search.html?query=%s", encodedQuery)
-	err := c.Visit(searchURL)
-	if err != nil {
-		return nil, err
 	}
-
 	return products, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,170 @@
+package scrapers
+
+import (
+	"smartyshop/internal"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// microdataProducts returns the schema.org Products described with
+// microdata (itemscope/itemprop attributes) in a document, in document order.
+func microdataProducts(doc *goquery.Selection) []internal.Product {
+	var products []internal.Product
+	doc.Find("[itemscope][itemtype]").Each(func(_ int, item *goquery.Selection) {
+		if !microdataTypeIs(item, "Product") {
+			return
+		}
+		products = append(products, productFromMicrodata(item))
+	})
+	return products
+}
+
+// microdataTypeIs reports whether the itemtype of an item is typ.
+func microdataTypeIs(item *goquery.Selection, typ string) bool {
+	for _, t := range strings.Fields(item.AttrOr("itemtype", "")) {
+		if ldName(t) == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// productFromMicrodata maps a schema.org Product item to a Product.
+func productFromMicrodata(item *goquery.Selection) internal.Product {
+	product := internal.Product{
+		Title:       microdataValue(item, "name"),
+		URL:         microdataValue(item, "url"),
+		Description: microdataValue(item, "description"),
+	}
+	microdataProps(item, "image").Each(func(_ int, image *goquery.Selection) {
+		if src := itempropValue(image); src != "" {
+			product.Images = append(product.Images, src)
+		}
+	})
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0]
+	}
+
+	brand := microdataProps(item, "brand").First()
+	if brand.Length() > 0 {
+		if _, scoped := brand.Attr("itemscope"); scoped {
+			product.Brand = microdataValue(brand, "name")
+		} else {
+			product.Brand = itempropValue(brand)
+		}
+	}
+
+	if offer := microdataProps(item, "offers").First(); offer.Length() > 0 {
+		product.Price = microdataPrice(offer)
+		if availability := microdataValue(offer, "availability"); availability != "" {
+			inStock := ldName(availability) == "InStock" || ldName(availability) == "LimitedAvailability"
+			product.InStock = &inStock
+		}
+		if seller := microdataProps(offer, "seller").First(); seller.Length() > 0 {
+			product.Seller = microdataValue(seller, "name")
+		}
+	}
+
+	rating := microdataProps(item, "aggregateRating").First()
+	product.SetScrapedRating(parseRating(microdataValue(rating, "ratingValue")))
+	product.ReviewsCount = parseCount(microdataValue(rating, "reviewCount"))
+	if product.ReviewsCount == 0 {
+		product.ReviewsCount = parseCount(microdataValue(rating, "ratingCount"))
+	}
+
+	return product
+}
+
+// microdataPrice returns the price of offer. A content attribute holds a
+// schema.org number such as "1299.90", while the text of an element is the
+// price as displayed, e.g. "1.299,90 TL".
+func microdataPrice(offer *goquery.Selection) internal.Money {
+	currency := microdataValue(offer, "priceCurrency")
+	for _, name := range []string{"price", "lowPrice"} {
+		var price internal.Money
+		microdataProps(offer, name).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			value := itempropValue(s)
+			if value == "" {
+				return true
+			}
+			if _, ok := s.Attr("content"); ok {
+				price = schemaPrice(value, currency)
+			} else {
+				price = internal.ParsePrice(value)
+				if currency != "" {
+					price.Currency = currency
+				}
+			}
+			return false
+		})
+		if price.Display != "" {
+			return price
+		}
+	}
+	return internal.Money{}
+}
+
+// microdataProps returns the elements carrying property name of item,
+// ignoring the properties of items nested in it.
+func microdataProps(item *goquery.Selection, name string) *goquery.Selection {
+	if item.Length() == 0 {
+		return item
+	}
+	scope := item.Get(0)
+	return item.Find("[itemprop]").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		if !hasToken(s.AttrOr("itemprop", ""), name) {
+			return false
+		}
+		owner := s.ParentsFiltered("[itemscope]").First()
+		return owner.Length() > 0 && owner.Get(0) == scope
+	})
+}
+
+// microdataValue returns the first non-empty value of property name of item.
+func microdataValue(item *goquery.Selection, name string) string {
+	value := ""
+	microdataProps(item, name).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		value = itempropValue(s)
+		return value == ""
+	})
+	return value
+}
+
+// itempropValue returns the value of a property element as defined by the
+// microdata specification: the content attribute when present, the URL of
+// links and media, and the text of any other element.
+func itempropValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	var attr string
+	switch goquery.NodeName(s) {
+	case "a", "area", "link":
+		attr = "href"
+	case "img", "audio", "embed", "iframe", "source", "track", "video":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "time":
+		attr = "datetime"
+	case "data", "meter":
+		attr = "value"
+	}
+	if attr != "" {
+		if value, ok := s.Attr(attr); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
+
+// hasToken reports whether the space separated list s contains token.
+func hasToken(s, token string) bool {
+	for _, t := range strings.Fields(s) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
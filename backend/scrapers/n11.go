@@ -0,0 +1,46 @@
+package scrapers
+
+import (
+	"context"
+	"smartyshop/internal"
+)
+
+// N11Scraper implements the Scraper interface for n11.
+type N11Scraper struct{}
+
+func init() {
+	Register(Site{
+		ID:           "n11",
+		Name:         "n11",
+		Domain:       "www.n11.com",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews},
+	}, func() Scraper { return &N11Scraper{} })
+
+	registerSiteConfig("n11", SiteConfig{
+		SearchURL: "https://www.n11.com/arama?q={query}",
+		PageParam: "pg",
+		Card:      "li.column div.columnContent",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {
+				{Selector: "h3.productName"},
+				{Selector: "a.plink", Attr: "title"},
+			},
+			FieldPrice: {{Selector: ".priceContainer .newPrice ins"}},
+			// Görseller tembel yüklenir, asıl adres data-original'dadır
+			FieldImage: {
+				{Selector: "img", Attr: "data-original"},
+				{Selector: "img", Attr: "src"},
+			},
+			FieldURL: {{Selector: "a.plink", Attr: "href"}},
+			// Puan yüzde olarak sınıf adında tutulur: "rating r90" → 4.5
+			FieldRating:  {{Selector: ".ratingCont .rating", Attr: "class", Pattern: `\br(\d+)\b`, OutOf: 100}},
+			FieldReviews: {{Selector: ".ratingCont .ratingText", Trim: "()"}},
+		},
+		Required: []string{FieldTitle, FieldURL},
+	})
+}
+
+// Scrape scrapes n11 for products.
+func (s *N11Scraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "n11"}).Scrape(ctx, query, opts)
+}
@@ -0,0 +1,231 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// ErrDisallowedByRobots is returned for requests that the site's robots.txt
+// does not allow.
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// robotsTTL is how long a site's robots.txt is cached.
+const robotsTTL = time.Hour
+
+// RateLimit configures how fast a site is crawled. Limits are shared by all
+// scrapes of the same host. Zero values disable the corresponding limit.
+type RateLimit struct {
+	// RequestsPerSecond is the maximum rate at which requests are started.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	// MaxConcurrency is the maximum number of requests in flight.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// RandomDelayMs is the upper bound of a random pause, in milliseconds,
+	// added before every request.
+	RandomDelayMs int `json:"random_delay_ms,omitempty"`
+}
+
+// defaultRateLimit applies to sites without a rate limit of their own.
+var defaultRateLimit = RateLimit{RequestsPerSecond: 2, MaxConcurrency: 4, RandomDelayMs: 300}
+
+// rateLimit returns the rate limit in effect for the site.
+func (cfg SiteConfig) rateLimit() RateLimit {
+	if cfg.RateLimit != nil {
+		return *cfg.RateLimit
+	}
+	return defaultRateLimit
+}
+
+// politeTransport holds requests back as required by the rate limit of
+// their host and, if robots is set, refuses the ones robots.txt disallows.
+type politeTransport struct {
+	next   http.RoundTripper
+	limit  RateLimit
+	robots bool
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.robots && req.URL.Path != "/robots.txt" {
+		allowed, err := robots.allowed(req.Context(), t, req.URL, req.UserAgent())
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowedByRobots, req.URL)
+		}
+	}
+
+	release, err := limiters.get(req.URL.Host).acquire(req.Context(), t.limit)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The request stays in flight until its body has been read.
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees the concurrency slot of a request when its body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// domainLimiter spaces out and bounds the concurrent requests to one host.
+type domainLimiter struct {
+	mu     sync.Mutex
+	next   time.Time
+	active int
+	freed  chan struct{}
+}
+
+// limiterPool holds the limiter of every host contacted so far.
+type limiterPool struct {
+	mu       sync.Mutex
+	limiters map[string]*domainLimiter
+}
+
+var limiters = &limiterPool{limiters: make(map[string]*domainLimiter)}
+
+// get returns the limiter of host.
+func (p *limiterPool) get(host string) *domainLimiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.limiters[host]
+	if !ok {
+		l = &domainLimiter{freed: make(chan struct{})}
+		p.limiters[host] = l
+	}
+	return l
+}
+
+// acquire waits until a request may be started under limit and returns the
+// function that marks it as done. It gives up with ctx's error.
+func (l *domainLimiter) acquire(ctx context.Context, limit RateLimit) (func(), error) {
+	l.mu.Lock()
+	for limit.MaxConcurrency > 0 && l.active >= limit.MaxConcurrency {
+		freed := l.freed
+		l.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.active++
+
+	start := time.Now()
+	if limit.RequestsPerSecond > 0 {
+		if l.next.After(start) {
+			start = l.next
+		}
+		l.next = start.Add(time.Duration(float64(time.Second) / limit.RequestsPerSecond))
+	}
+	l.mu.Unlock()
+
+	if limit.RandomDelayMs > 0 {
+		start = start.Add(time.Duration(rand.Int63n(int64(limit.RandomDelayMs)+1)) * time.Millisecond)
+	}
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			l.release()
+			return nil, ctx.Err()
+		}
+	}
+	return l.release, nil
+}
+
+// release marks a request as done and wakes up the requests waiting for it.
+func (l *domainLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// robotsEntry is a cached robots.txt.
+type robotsEntry struct {
+	data    *robotstxt.RobotsData
+	fetched time.Time
+}
+
+// robotsCache holds the robots.txt of every host checked so far.
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}
+
+var robots = &robotsCache{entries: make(map[string]robotsEntry)}
+
+// allowed reports whether robots.txt allows agent to fetch u. The file is
+// fetched through rt and cached for robotsTTL. Hosts whose robots.txt
+// cannot be fetched are allowed.
+func (c *robotsCache) allowed(ctx context.Context, rt http.RoundTripper, u *url.URL, agent string) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[u.Host]
+	c.mu.Unlock()
+
+	if !ok || time.Since(entry.fetched) > robotsTTL {
+		data, err := fetchRobots(ctx, rt, u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return true, nil
+		}
+		entry = robotsEntry{data: data, fetched: time.Now()}
+
+		c.mu.Lock()
+		c.entries[u.Host] = entry
+		c.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return entry.data.TestAgent(path, agent), nil
+}
+
+// fetchRobots fetches and parses the robots.txt of u's host.
+func fetchRobots(ctx context.Context, rt http.RoundTripper, u *url.URL) (*robotstxt.RobotsData, error) {
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return robotstxt.FromResponse(resp)
+}
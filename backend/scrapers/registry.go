@@ -0,0 +1,157 @@
+package scrapers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Capability describes a piece of information a site's scraper is able to extract.
+type Capability string
+
+// Capabilities advertised by the registered scrapers.
+const (
+	CapabilitySearch      Capability = "search"
+	CapabilityRating      Capability = "rating"
+	CapabilityReviews     Capability = "reviews"
+	CapabilityDescription Capability = "description"
+	CapabilityDetail      Capability = "detail"
+)
+
+// Site describes a store that can be scraped.
+type Site struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Domain       string       `json:"domain"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Factory creates a new scraper for a registered site.
+type Factory func() Scraper
+
+type registration struct {
+	site    Site
+	factory Factory
+	// configured marks sites defined by the selector configuration file
+	// alone, which come and go with the file.
+	configured bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register makes a scraper available under site.ID. It is meant to be called
+// from the init function of the file implementing the scraper and panics if
+// the ID is empty or already registered.
+func Register(site Site, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if site.ID == "" {
+		panic("scrapers: Register called with an empty site ID")
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("scrapers: Register called with a nil factory for %q", site.ID))
+	}
+	if _, dup := registry[site.ID]; dup {
+		panic(fmt.Sprintf("scrapers: Register called twice for %q", site.ID))
+	}
+	registry[site.ID] = registration{site: site, factory: factory}
+}
+
+// registerConfigured registers a site defined by the selector configuration
+// file, replacing its previous registration.
+func registerConfigured(site Site, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[site.ID] = registration{site: site, factory: factory, configured: true}
+}
+
+// unregisterConfigured removes the sites defined by the selector
+// configuration file that are not in keep.
+func unregisterConfigured(keep map[string]SiteConfig) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for id, reg := range registry {
+		if _, ok := keep[id]; reg.configured && !ok {
+			delete(registry, id)
+		}
+	}
+}
+
+// isBuiltIn reports whether id is registered by a scraper of this package
+// rather than the selector configuration file.
+func isBuiltIn(id string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[id]
+	return ok && !reg.configured
+}
+
+// Lookup returns a new scraper for the site with the given ID.
+func Lookup(id string) (Scraper, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[id]
+	if !ok {
+		return nil, false
+	}
+	return reg.factory(), true
+}
+
+// LookupSite returns the description of the site with the given ID.
+func LookupSite(id string) (Site, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[id]
+	return reg.site, ok
+}
+
+// Sites returns all registered sites sorted by ID.
+func Sites() []Site {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	sites := make([]Site, 0, len(registry))
+	for _, reg := range registry {
+		sites = append(sites, reg.site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].ID < sites[j].ID
+	})
+	return sites
+}
+
+// SiteIDs returns the IDs of all registered sites sorted alphabetically.
+func SiteIDs() []string {
+	sites := Sites()
+	ids := make([]string, len(sites))
+	for i, site := range sites {
+		ids[i] = site.ID
+	}
+	return ids
+}
+
+// LookupURL returns the registered site serving rawURL, on its domain or
+// any of its subdomains, e.g. "m.trendyol.com".
+func LookupURL(rawURL string) (Site, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Site{}, false
+	}
+
+	for _, site := range Sites() {
+		if onSite(u.Hostname(), siteDomain(site.Domain)) {
+			return site, true
+		}
+	}
+	return Site{}, false
+}
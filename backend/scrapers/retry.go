@@ -0,0 +1,108 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry policy for transient failures of a request.
+var (
+	// retryAttempts is the maximum number of attempts per request.
+	retryAttempts = 3
+	// retryBaseDelay is the backoff before the first retry. It doubles with
+	// every further retry, up to retryMaxDelay.
+	retryBaseDelay = 250 * time.Millisecond
+	retryMaxDelay  = 4 * time.Second
+)
+
+// retryTransport retries requests that failed with a network error or a
+// status code that usually signals a transient problem, waiting a randomized
+// exponential backoff between attempts. onRetry, if set, is called before
+// every retry.
+type retryTransport struct {
+	next    http.RoundTripper
+	onRetry func()
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= retryAttempts || ctx.Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		if resp != nil {
+			if after := retryAfter(resp); after > delay {
+				delay = after
+			}
+			// Drain the body so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		if t.onRetry != nil {
+			t.onRetry()
+		}
+	}
+}
+
+// retryable reports whether a request that ended with resp and err is worth
+// another attempt.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrDisallowedByRobots) && !errors.Is(err, ErrNoHealthyProxy) && !isBlockError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number attempt, chosen at random
+// up to an exponentially growing bound ("full jitter").
+func backoff(attempt int) time.Duration {
+	bound := retryBaseDelay << uint(attempt-1)
+	if bound <= 0 || bound > retryMaxDelay {
+		bound = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(bound) + 1))
+}
+
+// retryAfter returns the delay requested by the Retry-After header of resp,
+// capped at retryMaxDelay. Only the delay-seconds form is supported.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	delay := time.Duration(seconds) * time.Second
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
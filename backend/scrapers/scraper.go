@@ -1,8 +1,49 @@
 package scrapers
 
-import "smartyshop/internal"
+import (
+	"context"
+	"smartyshop/internal"
+)
+
+// Pagination defaults and bounds for Options.
+const (
+	// DefaultMaxPages is the number of result pages followed when
+	// Options.MaxPages is not set.
+	DefaultMaxPages = 1
+	// MaxPagesLimit caps Options.MaxPages to keep scrapes polite.
+	MaxPagesLimit = 5
+)
+
+// Options controls how many results a scraper collects.
+type Options struct {
+	// MaxPages is the number of search result pages to follow. Values below
+	// 1 select DefaultMaxPages and values above MaxPagesLimit are capped.
+	MaxPages int
+	// Limit caps the number of products returned. 0 means no limit.
+	Limit int
+}
+
+// pages returns the number of result pages to follow.
+func (o Options) pages() int {
+	switch {
+	case o.MaxPages < 1:
+		return DefaultMaxPages
+	case o.MaxPages > MaxPagesLimit:
+		return MaxPagesLimit
+	default:
+		return o.MaxPages
+	}
+}
+
+// full reports whether n products satisfy the limit.
+func (o Options) full(n int) bool {
+	return o.Limit > 0 && n >= o.Limit
+}
 
 // Scraper defines the interface for a scraper.
+//
+// Implementations must stop issuing requests and return ctx.Err() once ctx
+// is cancelled or its deadline expires.
 type Scraper interface {
-	Scrape(query string) ([]internal.Product, error)
+	Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error)
 }
@@ -0,0 +1,1013 @@
+package scrapers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"smartyshop/internal"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	// Saved pages are served locally, there is nobody to be polite to.
+	defaultRateLimit = RateLimit{}
+	retryBaseDelay = time.Millisecond
+	os.Exit(m.Run())
+}
+
+// fixtureTransport answers requests with the pages saved in dir instead of
+// contacting the store. The first result page is search.html and later ones
+// are search-page<N>.html, N being the value of the site's page parameter.
+// When page is set, every request is answered with that file instead, and
+// when apiHost is set, requests to it are answered with api.json.
+type fixtureTransport struct {
+	dir       string
+	pageParam string
+	page      string
+	apiHost   string
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := "search.html"
+	if page := req.URL.Query().Get(t.pageParam); t.pageParam != "" && page != "" {
+		name = "search-page" + page + ".html"
+	}
+	if t.page != "" {
+		name = t.page
+	}
+	if t.apiHost != "" && req.URL.Hostname() == t.apiHost {
+		name = "api.json"
+	}
+	if req.URL.Path == "/robots.txt" {
+		name = "robots.txt"
+	}
+
+	body, err := os.ReadFile(filepath.Join(t.dir, name))
+	if err != nil {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader(err.Error())),
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// useFixtures routes all collector traffic to the pages saved for siteID
+// until the test finishes. Requests to the search API of a site are answered
+// with its search page too, which the API parser rejects, so the scrapers
+// fall back to the page selectors under test.
+func useFixtures(t *testing.T, siteID string) {
+	t.Helper()
+
+	cfg, _ := siteConfig(siteID)
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", siteID), pageParam: cfg.PageParam}
+	t.Cleanup(func() { baseTransport = previous })
+}
+
+// useAPIFixture answers the search API requests of siteID with its saved
+// api.json until the test finishes.
+func useAPIFixture(t *testing.T, siteID string) {
+	t.Helper()
+
+	cfg, _ := siteConfig(siteID)
+	endpoint, err := url.Parse(cfg.APIURL)
+	if err != nil || cfg.APIURL == "" {
+		t.Fatalf("%s has no search API URL", siteID)
+	}
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", siteID), apiHost: endpoint.Hostname()}
+	t.Cleanup(func() { baseTransport = previous })
+}
+
+// useDetailFixture answers all collector traffic with the saved product
+// detail page of siteID until the test finishes.
+func useDetailFixture(t *testing.T, siteID string) {
+	t.Helper()
+
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", siteID), page: "detail.html"}
+	t.Cleanup(func() { baseTransport = previous })
+}
+
+// checkGolden compares products with the golden file at path, rewriting it
+// when the -update flag is set.
+func checkGolden(t *testing.T, path string, v interface{}) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("result differs from %s (run with -update to accept):\n%s", path, got)
+	}
+}
+
+// TestScrapersAgainstFixtures runs every registered scraper against its saved
+// search page and compares the extracted products with the golden output.
+func TestScrapersAgainstFixtures(t *testing.T) {
+	for _, site := range Sites() {
+		site := site
+		t.Run(site.ID, func(t *testing.T) {
+			dir := filepath.Join("testdata", site.ID)
+			if _, err := os.Stat(filepath.Join(dir, "search.html")); err != nil {
+				t.Fatalf("no saved search page for %s: %v", site.ID, err)
+			}
+			useFixtures(t, site.ID)
+
+			scraper, _ := Lookup(site.ID)
+			products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if len(products) == 0 {
+				t.Fatalf("Scrape returned no products, selectors no longer match the saved page")
+			}
+			for i, p := range products {
+				if p.Title == "" || p.URL == "" || p.Price.IsZero() {
+					t.Errorf("product %d is missing a title, URL or price: %+v", i, p)
+				}
+			}
+
+			checkGolden(t, filepath.Join(dir, "search.golden.json"), products)
+		})
+	}
+}
+
+// TestDetailAgainstFixtures reads the saved product detail pages and compares
+// the extracted details with the golden output.
+func TestDetailAgainstFixtures(t *testing.T) {
+	cases := []struct {
+		site string
+		url  string
+	}{
+		{"trendyol", "https://www.trendyol.com/apple/iphone-15-128-gb-siyah-p-759190940"},
+		{"mediamarkt", "https://www.mediamarkt.com.tr/tr/product/_apple-iphone-15-128gb-akilli-telefon-mavi-1234567.html"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.site, func(t *testing.T) {
+			useDetailFixture(t, tc.site)
+
+			site, ok := LookupURL(tc.url)
+			if !ok || site.ID != tc.site {
+				t.Fatalf("LookupURL(%q) = %q, %v; want %q", tc.url, site.ID, ok, tc.site)
+			}
+			scraper, _ := Lookup(site.ID)
+			detailScraper, ok := scraper.(DetailScraper)
+			if !ok {
+				t.Fatalf("%s does not implement DetailScraper", site.ID)
+			}
+
+			product, err := detailScraper.Detail(context.Background(), tc.url)
+			if err != nil {
+				t.Fatalf("Detail: %v", err)
+			}
+			if product.Brand == "" || product.InStock == nil || len(product.Specs) == 0 {
+				t.Errorf("detail is missing the brand, stock or specs: %+v", product)
+			}
+
+			checkGolden(t, filepath.Join("testdata", tc.site, "detail.golden.json"), product)
+		})
+	}
+}
+
+// TestLookupURLMatchesSubdomains checks that product URLs on any subdomain
+// of a site, e.g. its mobile site, belong to the site and can be detailed.
+func TestLookupURLMatchesSubdomains(t *testing.T) {
+	tests := []struct {
+		url  string
+		site string
+	}{
+		{"https://www.trendyol.com/apple/iphone-15-p-759190940", "trendyol"},
+		{"https://trendyol.com/apple/iphone-15-p-759190940", "trendyol"},
+		{"https://M.Trendyol.com/apple/iphone-15-p-759190940", "trendyol"},
+		{"https://www.hepsiburada.com/apple-iphone-15-p-HBC00004X9LJ1", "hepsiburada"},
+		{"https://eviltrendyol.com/apple/iphone-15-p-759190940", ""},
+		{"https://trendyol.com.example.com/apple/iphone-15-p-759190940", ""},
+	}
+	for _, tt := range tests {
+		site, ok := LookupURL(tt.url)
+		if ok != (tt.site != "") || site.ID != tt.site {
+			t.Errorf("LookupURL(%q) = %q, %v; want %q", tt.url, site.ID, ok, tt.site)
+		}
+	}
+
+	useDetailFixture(t, "trendyol")
+	scraper, _ := Lookup("trendyol")
+	product, err := scraper.(DetailScraper).Detail(context.Background(), "https://m.trendyol.com/apple/iphone-15-128-gb-siyah-p-759190940")
+	if err != nil || product.Brand != "Apple" {
+		t.Errorf("Detail on the mobile site = %+v, %v", product, err)
+	}
+}
+
+// TestEnrichKeepsSearchValues checks that enrichment only fills the gaps of
+// a search result and leaves the original slice untouched.
+func TestEnrichKeepsSearchValues(t *testing.T) {
+	useDetailFixture(t, "trendyol")
+
+	search := []internal.Product{{
+		Title: "Apple iPhone 15 128 GB",
+		Price: internal.ParsePrice("51.999 TL"),
+		URL:   "https://www.trendyol.com/apple/iphone-15-128-gb-siyah-p-759190940",
+		Site:  "Trendyol",
+	}}
+	search[0].SetScrapedRating(0)
+
+	enriched := Enrich(context.Background(), search, 10)
+
+	if search[0].Brand != "" {
+		t.Errorf("Enrich modified its input: %+v", search[0])
+	}
+	got := enriched[0]
+	if got.Title != search[0].Title || got.Price != search[0].Price {
+		t.Errorf("Enrich replaced search values: title %q, price %+v", got.Title, got.Price)
+	}
+	if got.Brand != "Apple" || got.Seller != "Apple Türkiye" || len(got.Images) != 3 {
+		t.Errorf("Enrich did not fill the details: %+v", got)
+	}
+	if !got.HasRating() || got.RatingSource != internal.RatingScraped {
+		t.Errorf("Enrich did not fill the missing rating: %+v", got)
+	}
+}
+
+// TestEnrichFailuresKeepSearchBreakerClosed checks that failing detail pages
+// open their own breaker and leave the searches of the site running.
+func TestEnrichFailuresKeepSearchBreakerClosed(t *testing.T) {
+	previous := baseTransport
+	baseTransport = &responseTransport{status: http.StatusServiceUnavailable, header: http.Header{"Content-Type": []string{"text/plain"}}}
+	previousBreakers := breakers
+	breakers = &breakerPool{breakers: make(map[string]*circuitBreaker)}
+	t.Cleanup(func() {
+		baseTransport = previous
+		breakers = previousBreakers
+	})
+
+	var search []internal.Product
+	for i := 0; i < 2*breakerThreshold; i++ {
+		search = append(search, internal.Product{
+			Title: "Apple iPhone 15 128 GB",
+			URL:   fmt.Sprintf("https://www.trendyol.com/apple/iphone-15-p-%d", i),
+			Site:  "Trendyol",
+		})
+	}
+	Enrich(context.Background(), search, len(search))
+
+	if state := Breaker("trendyol"); state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Errorf("search breaker = %+v, want it closed", state)
+	}
+	if state := Breaker(detailBreaker("trendyol")); state.State != BreakerOpen {
+		t.Errorf("detail breaker = %s, want %s", state.State, BreakerOpen)
+	}
+}
+
+// TestPageURLEncodesQuery checks that Turkish characters are replaced for
+// the sites that require it and that queries in the URL path are
+// path-escaped.
+func TestPageURLEncodesQuery(t *testing.T) {
+	cases := []struct {
+		site  string
+		query string
+		page  int
+		want  string
+	}{
+		{"vatan", "şarj kablosu", 0, "https://www.vatanbilgisayar.com/arama/sarj%20kablosu/"},
+		{"vatan", "şarj kablosu", 1, "https://www.vatanbilgisayar.com/arama/sarj%20kablosu/?page=2"},
+		{"n11", "şarj kablosu", 0, "https://www.n11.com/arama?q=%C5%9Farj+kablosu"},
+		{"n11", "şarj kablosu", 2, "https://www.n11.com/arama?q=%C5%9Farj+kablosu&pg=3"},
+	}
+
+	for _, tc := range cases {
+		cfg, ok := siteConfig(tc.site)
+		if !ok {
+			t.Fatalf("no config for %s", tc.site)
+		}
+		if got := cfg.pageURL(tc.query, tc.page); got != tc.want {
+			t.Errorf("%s page %d: pageURL(%q) = %q, want %q", tc.site, tc.page, tc.query, got, tc.want)
+		}
+	}
+}
+
+// TestSearchAPIAgainstFixtures runs the sites using StrategyAPI against their
+// saved search API responses and compares the products with the golden
+// output.
+func TestSearchAPIAgainstFixtures(t *testing.T) {
+	for _, site := range Sites() {
+		site := site
+		if cfg, _ := siteConfig(site.ID); cfg.Strategy != StrategyAPI {
+			continue
+		}
+		t.Run(site.ID, func(t *testing.T) {
+			useAPIFixture(t, site.ID)
+
+			scraper, _ := Lookup(site.ID)
+			products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if len(products) == 0 {
+				t.Fatalf("Scrape returned no products from the search API")
+			}
+			for i, p := range products {
+				if p.Title == "" || p.URL == "" || p.Price.IsZero() || p.Brand == "" {
+					t.Errorf("product %d is missing a title, URL, price or brand: %+v", i, p)
+				}
+			}
+			if report := health.report(site.ID); report.Last == nil || report.Last.Source != SourceAPI {
+				t.Errorf("scrape not recorded as an API scrape: %+v", report.Last)
+			}
+
+			checkGolden(t, filepath.Join("testdata", site.ID, "api.golden.json"), products)
+		})
+	}
+}
+
+// TestSearchAPIFallsBackToHTML checks that a failing search API is replaced
+// by the result page selectors and that the fallback shows in the health.
+func TestSearchAPIFallsBackToHTML(t *testing.T) {
+	useFixtures(t, "trendyol")
+
+	scraper, _ := Lookup("trendyol")
+	products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if len(products) != 3 {
+		t.Errorf("got %d products from the result page, want 3", len(products))
+	}
+
+	report := health.report("trendyol")
+	if report.Last == nil || report.Last.Source != SourceSelectors || !report.Last.Fallback {
+		t.Errorf("fallback not recorded in the site health: %+v", report.Last)
+	}
+}
+
+// useStructuredFixture answers all collector traffic with the saved page
+// testdata/structured/<page> until the test finishes.
+func useStructuredFixture(t *testing.T, page string) {
+	t.Helper()
+
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", "structured"), page: page}
+	t.Cleanup(func() { baseTransport = previous })
+}
+
+// TestSchemaPrice checks that schema.org prices are read as dot-decimal
+// numbers and prices written out as displayed as Turkish prices.
+func TestSchemaPrice(t *testing.T) {
+	tests := []struct {
+		value, currency string
+		amount          int64
+		wantCurrency    string
+	}{
+		{"12.500", "", 1250, internal.CurrencyTRY},
+		{"1299.90", "TRY", 129990, internal.CurrencyTRY},
+		{"44999", "EUR", 4499900, "EUR"},
+		{"1.299,90 TL", "", 129990, internal.CurrencyTRY},
+		{"12.500 TL", "", 1250000, internal.CurrencyTRY},
+	}
+	for _, tt := range tests {
+		if got := schemaPrice(tt.value, tt.currency); got.Amount != tt.amount || got.Currency != tt.wantCurrency {
+			t.Errorf("schemaPrice(%q, %q) = %+v, want %d %s", tt.value, tt.currency, got, tt.amount, tt.wantCurrency)
+		}
+	}
+
+	// Microdata content attributes are numbers, element texts displayed prices.
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`
+		<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">Kılıf</span>
+		<div itemprop="offers" itemscope itemtype="https://schema.org/Offer"><meta itemprop="price" content="12.500"></div></div>
+		<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">Telefon</span>
+		<div itemprop="offers" itemscope itemtype="https://schema.org/Offer"><span itemprop="price">12.500 TL</span></div></div>`))
+	products := microdataProducts(doc.Selection)
+	if len(products) != 2 || products[0].Price.Amount != 1250 || products[1].Price.Amount != 1250000 {
+		t.Errorf("microdata prices = %+v", products)
+	}
+}
+
+// TestStructuredDataScraper reads products from JSON-LD and microdata search
+// pages and compares them with the golden output.
+func TestStructuredDataScraper(t *testing.T) {
+	for _, page := range []string{"jsonld", "microdata"} {
+		page := page
+		t.Run(page, func(t *testing.T) {
+			useStructuredFixture(t, page+".html")
+
+			scraper := &StructuredDataScraper{SiteID: "trendyol"}
+			products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if len(products) == 0 {
+				t.Fatalf("Scrape found no structured data")
+			}
+			for i, p := range products {
+				if p.Title == "" || !strings.HasPrefix(p.URL, "https://www.trendyol.com/") {
+					t.Errorf("product %d has no title or an unresolved URL: %+v", i, p)
+				}
+			}
+
+			checkGolden(t, filepath.Join("testdata", "structured", page+".golden.json"), products)
+		})
+	}
+}
+
+// TestScrapeFallsBackToStructuredData checks that a site whose selectors no
+// longer match is served from the structured data of its pages and flagged
+// in the health report.
+func TestScrapeFallsBackToStructuredData(t *testing.T) {
+	if err := useSelectorConfig(t, `{"sites": {"trendyol": {"card": ".p-card-renamed"}}}`); err != nil {
+		t.Fatalf("LoadSelectorConfig: %v", err)
+	}
+	useStructuredFixture(t, "jsonld.html")
+
+	scraper, _ := Lookup("trendyol")
+	products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if len(products) != 3 {
+		t.Fatalf("got %d products from structured data, want 3", len(products))
+	}
+
+	report := health.report("trendyol")
+	if report.Status != HealthDegraded || report.Last == nil || !report.Last.Fallback {
+		t.Errorf("fallback not reported in site health: %+v", report)
+	}
+}
+
+// TestDomainLimiterSpacesRequests checks the request rate and concurrency
+// limits of a host.
+func TestDomainLimiterSpacesRequests(t *testing.T) {
+	ctx := context.Background()
+
+	l := &domainLimiter{freed: make(chan struct{})}
+	limit := RateLimit{RequestsPerSecond: 50}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := l.acquire(ctx, limit)
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		release()
+	}
+	// The first request starts at once, the next four 20ms apart.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 requests at 50/s took %v, want at least 80ms", elapsed)
+	}
+
+	l = &domainLimiter{freed: make(chan struct{})}
+	limit = RateLimit{MaxConcurrency: 1}
+	release, err := l.acquire(ctx, limit)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(waitCtx, limit); err != context.DeadlineExceeded {
+		t.Fatalf("second acquire = %v, want it to wait for the first request", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if release, err := l.acquire(ctx, limit); err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("waiting request not started after the first one finished")
+	}
+}
+
+// TestScrapeRespectsRobotsTxt checks that sites configured to respect
+// robots.txt do not fetch disallowed pages.
+func TestScrapeRespectsRobotsTxt(t *testing.T) {
+	if err := useSelectorConfig(t, `{"sites": {"trendyol": {"strategy": "html", "respect_robots_txt": true}}}`); err != nil {
+		t.Fatalf("LoadSelectorConfig: %v", err)
+	}
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", "robots")}
+	t.Cleanup(func() { baseTransport = previous })
+
+	scraper, _ := Lookup("trendyol")
+	_, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("Scrape error = %v, want %v", err, ErrDisallowedByRobots)
+	}
+}
+
+// stubTransport answers requests with the given status codes in turn.
+type stubTransport struct {
+	statuses []int
+	calls    int
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := t.statuses[t.calls]
+	if t.calls < len(t.statuses)-1 {
+		t.calls++
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(http.StatusText(status))),
+		Request:    req,
+	}, nil
+}
+
+// TestRetryTransportRetriesTransientFailures checks which responses are
+// retried and that retries stop after retryAttempts.
+func TestRetryTransportRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+		retries  int
+	}{
+		{"success", []int{200}, 200, 0},
+		{"recovers", []int{503, 502, 200}, 200, 2},
+		{"gives up", []int{503}, 503, retryAttempts - 1},
+		{"not transient", []int{404, 200}, 404, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries := 0
+			rt := &retryTransport{next: &stubTransport{statuses: tt.statuses}, onRetry: func() { retries++ }}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://www.trendyol.com/sr?q=iphone", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want || retries != tt.retries {
+				t.Errorf("got status %d after %d retries, want %d after %d", resp.StatusCode, retries, tt.want, tt.retries)
+			}
+		})
+	}
+}
+
+// TestCircuitBreakerOpensAndRecovers walks a breaker through its states.
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	b := &circuitBreaker{state: BreakerClosed}
+	failure := errors.New("HTTP 503")
+	for i := 0; i < breakerThreshold; i++ {
+		if err := b.allow("test"); err != nil {
+			t.Fatalf("scrape %d rejected while closed: %v", i+1, err)
+		}
+		b.record(failure)
+	}
+	if err := b.allow("test"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after %d failures = %v, want %v", breakerThreshold, err, ErrCircuitOpen)
+	}
+
+	// After the cool-down a single trial scrape is let through.
+	clock = clock.Add(breakerCooldown)
+	if err := b.allow("test"); err != nil {
+		t.Fatalf("trial scrape rejected: %v", err)
+	}
+	if err := b.allow("test"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second scrape during the trial = %v, want %v", err, ErrCircuitOpen)
+	}
+	b.record(failure)
+	if state := b.snapshot(); state.State != BreakerOpen {
+		t.Fatalf("failed trial left the breaker %s, want %s", state.State, BreakerOpen)
+	}
+
+	clock = clock.Add(breakerCooldown)
+	if err := b.allow("test"); err != nil {
+		t.Fatalf("trial scrape rejected: %v", err)
+	}
+	b.record(nil)
+	if state := b.snapshot(); state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Errorf("successful trial left the breaker %+v, want it closed", state)
+	}
+}
+
+// responseTransport answers every request with the same response.
+type responseTransport struct {
+	status int
+	header http.Header
+	body   string
+}
+
+func (t *responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     t.header,
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+// TestBlockTransportDetectsBlockPages checks that block pages, CAPTCHAs,
+// consent walls and unexpected redirects are reported as typed errors.
+func TestBlockTransportDetectsBlockPages(t *testing.T) {
+	html := http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}
+	redirect := func(location string) http.Header {
+		return http.Header{"Location": []string{location}}
+	}
+
+	tests := []struct {
+		name string
+		resp *responseTransport
+		want error
+	}{
+		{"result page", &responseTransport{200, html, `<html><title>iphone 15 - Trendyol</title></html>`}, nil},
+		{"forbidden", &responseTransport{403, html, `<html><title>Forbidden</title></html>`}, ErrBlocked},
+		{"access denied page", &responseTransport{200, html, `<html><head><title>Access Denied</title></head></html>`}, ErrBlocked},
+		{"recaptcha", &responseTransport{200, html, `<div class="g-recaptcha" data-sitekey="x"></div>`}, ErrCaptcha},
+		{"cloudflare challenge", &responseTransport{403, html, `<html><head><title>Just a moment...</title></head></html>`}, ErrCaptcha},
+		{"consent page", &responseTransport{200, html, `<html><head><title>Çerez Tercihleri</title></head></html>`}, ErrConsentWall},
+		{"consent redirect", &responseTransport{302, redirect("https://consent.trendyol.com/?continue=/sr"), ""}, ErrConsentWall},
+		{"login redirect", &responseTransport{302, redirect("/giris?redirect=/sr"), ""}, ErrUnexpectedRedirect},
+		{"off-site redirect", &responseTransport{301, redirect("https://www.example.com/"), ""}, ErrUnexpectedRedirect},
+		{"same-site redirect", &responseTransport{301, redirect("https://m.trendyol.com/sr?q=iphone"), ""}, nil},
+		{"json", &responseTransport{200, http.Header{"Content-Type": []string{"application/json"}}, `{"captcha": "g-recaptcha"}`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &blockTransport{next: tt.resp, domain: "trendyol.com"}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://www.trendyol.com/sr?q=iphone", nil)
+			resp, err := rt.RoundTrip(req)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("RoundTrip: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != tt.resp.body {
+					t.Errorf("body = %q, want it passed through unchanged", body)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("RoundTrip error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeReportsCaptcha(t *testing.T) {
+	previous := baseTransport
+	baseTransport = &fixtureTransport{dir: filepath.Join("testdata", "amazon"), page: "captcha.html"}
+	t.Cleanup(func() { baseTransport = previous })
+
+	scraper, _ := Lookup("amazon")
+	products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if !errors.Is(err, ErrCaptcha) {
+		t.Fatalf("Scrape error = %v, want %v", err, ErrCaptcha)
+	}
+	if len(products) != 0 {
+		t.Errorf("Scrape returned %d products from a CAPTCHA page", len(products))
+	}
+
+	report := health.report("amazon")
+	if report.Status != HealthFailing || report.Errors[ErrorKindCaptcha] == 0 {
+		t.Errorf("health = %s with errors %v, want failing with a %s error", report.Status, report.Errors, ErrorKindCaptcha)
+	}
+}
+
+// proxyStandIn starts a local HTTP proxy stand-in that answers every
+// request itself with its name.
+func proxyStandIn(t *testing.T, name string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// TestProxyPoolRotatesOverHealthyProxies sends requests through local proxy
+// stand-ins and checks that failing proxies leave and rejoin the rotation.
+func TestProxyPoolRotatesOverHealthyProxies(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	pool, err := newProxyPool(ProxyConfig{
+		URLs:     []string{proxyStandIn(t, "a"), proxyStandIn(t, "b"), dead.URL},
+		CheckURL: "http://check.test/",
+	}, newProxyTransport)
+	if err != nil {
+		t.Fatalf("newProxyPool: %v", err)
+	}
+
+	fetch := func() string {
+		req, _ := http.NewRequest(http.MethodGet, "http://shop.test/sr?q=iphone", nil)
+		resp, err := pool.RoundTrip(req)
+		if err != nil {
+			return "error"
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	var got []string
+	for i := 0; i < 3*proxyFailureThreshold; i++ {
+		got = append(got, fetch())
+	}
+	want := []string{"a", "b", "error", "a", "b", "error", "a", "b", "error"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("requests went %v, want %v", got, want)
+	}
+
+	// The dead proxy failed proxyFailureThreshold times and is skipped now.
+	if got := []string{fetch(), fetch(), fetch()}; strings.Join(got, " ") != "a b a" {
+		t.Errorf("requests after the dead proxy failed went %v, want [a b a]", got)
+	}
+	status := pool.status()
+	if status[2].Healthy || status[2].RetryAt == nil || !status[0].Healthy {
+		t.Errorf("status = %+v, want only the dead proxy unhealthy", status)
+	}
+
+	// A health check keeps the live proxies and the dead one out.
+	pool.check(context.Background())
+	if status := pool.status(); !status[0].Healthy || !status[1].Healthy || status[2].Healthy {
+		t.Errorf("status after check = %+v", status)
+	}
+
+	// After the cool-down the dead proxy gets another chance.
+	clock = clock.Add(proxyCooldown)
+	if got := []string{fetch(), fetch()}; !strings.Contains(strings.Join(got, " "), "error") {
+		t.Errorf("dead proxy not retried after its cool-down, requests went %v", got)
+	}
+}
+
+// recordingTransport records the requests it serves and sets a cookie.
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{"text/html; charset=utf-8"},
+			"Set-Cookie":   []string{"session=abc; Path=/"},
+		},
+		Body:    io.NopCloser(strings.NewReader("<html></html>")),
+		Request: req,
+	}, nil
+}
+
+// TestCollectorSendsBrowserProfileAndKeepsCookies checks that collectors
+// send the headers of a browser profile and share the cookies of a site.
+func TestCollectorSendsBrowserProfileAndKeepsCookies(t *testing.T) {
+	rt := &recordingTransport{}
+	previous := baseTransport
+	baseTransport = rt
+	t.Cleanup(func() { baseTransport = previous })
+
+	for i := 0; i < 2; i++ {
+		c := newCollector(context.Background(), newDiagnostics("vatan", "iphone"))
+		if err := c.Visit("https://www.vatanbilgisayar.com/arama/iphone/"); err != nil {
+			t.Fatalf("Visit: %v", err)
+		}
+	}
+	if len(rt.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(rt.requests))
+	}
+
+	for _, req := range rt.requests {
+		ua := req.Header.Get("User-Agent")
+		if !strings.HasPrefix(ua, "Mozilla/5.0") || req.Header.Get("Accept-Language") == "" {
+			t.Errorf("request sent User-Agent %q and Accept-Language %q, want a browser profile", ua, req.Header.Get("Accept-Language"))
+		}
+	}
+	if cookie := rt.requests[1].Header.Get("Cookie"); cookie != "session=abc" {
+		t.Errorf("second scrape sent Cookie %q, want the cookie set during the first", cookie)
+	}
+}
+
+// useSelectorConfig applies the selector configuration in content and
+// restores the built-in selectors when the test finishes.
+func useSelectorConfig(t *testing.T, content string) error {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "selectors.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write selector config: %v", err)
+	}
+	t.Cleanup(func() {
+		reset := filepath.Join(dir, "reset.json")
+		if err := os.WriteFile(reset, []byte(`{"sites": {}}`), 0o644); err != nil {
+			t.Fatalf("write selector config: %v", err)
+		}
+		if err := LoadSelectorConfig(reset); err != nil {
+			t.Fatalf("reset selector config: %v", err)
+		}
+	})
+	return LoadSelectorConfig(path)
+}
+
+func TestSelectorConfigOverridesBuiltInSelectors(t *testing.T) {
+	useFixtures(t, "trendyol")
+	scraper, _ := Lookup("trendyol")
+
+	err := useSelectorConfig(t, `{"sites": {"trendyol": {"fields": {"title": [{"selector": ".prdct-desc-cntnr-ttl"}]}}}}`)
+	if err != nil {
+		t.Fatalf("LoadSelectorConfig: %v", err)
+	}
+	products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if len(products) == 0 || products[0].Title != "Apple" {
+		t.Fatalf("override not applied, got %+v", products)
+	}
+	if products[0].Price.IsZero() {
+		t.Errorf("fields that were not overridden lost their built-in selectors")
+	}
+
+	// An invalid file must leave the configuration in effect untouched.
+	if err := useSelectorConfig(t, `{"sites": {"trendyol": {"fields": {"title": [{"pattern": "("}]}}}}`); err == nil {
+		t.Fatalf("LoadSelectorConfig accepted an invalid pattern")
+	}
+	products, _ = scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if len(products) == 0 || products[0].Title != "Apple" {
+		t.Errorf("invalid config replaced the previous one, got %+v", products)
+	}
+}
+
+func TestJoinedFieldRule(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`
+		<div class="whole-and-fraction"><span class="a-price"><span class="a-offscreen">1.299,00 TL</span><span class="a-price-whole">1.299,</span><span class="a-price-fraction">00</span></span></div>
+		<div class="offscreen-only"><span class="a-price"><span class="a-offscreen">1.299,00 TL</span><span class="a-price-whole">1.299,</span></span></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	site, _ := siteConfig("amazon")
+	rules := site.Fields[FieldPrice]
+
+	if got := extractField(doc.Find(".whole-and-fraction"), rules); got != "1.299,00" {
+		t.Errorf("joined price = %q, want %q", got, "1.299,00")
+	}
+	// A missing part falls back to the next rule.
+	if got := extractField(doc.Find(".offscreen-only"), rules); got != "1.299,00 TL" {
+		t.Errorf("fallback price = %q, want %q", got, "1.299,00 TL")
+	}
+}
+
+func TestSelectorConfigOnboardsNewSite(t *testing.T) {
+	err := useSelectorConfig(t, `{"sites": {"yenimagaza": {
+		"name": "Yeni Mağaza",
+		"domain": "www.yenimagaza.com.tr",
+		"search_url": "https://www.yenimagaza.com.tr/arama?q={query}"
+	}}}`)
+	if err != nil {
+		t.Fatalf("LoadSelectorConfig: %v", err)
+	}
+	useStructuredFixture(t, "jsonld.html")
+
+	site, ok := LookupSite("yenimagaza")
+	if !ok || site.Name != "Yeni Mağaza" || site.Domain != "www.yenimagaza.com.tr" {
+		t.Fatalf("site not registered from the config file: %+v", site)
+	}
+	scraper, _ := Lookup("yenimagaza")
+	products, err := scraper.Scrape(context.Background(), "iphone 15", Options{})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if len(products) != 3 || products[0].Site != "Yeni Mağaza" || !strings.HasPrefix(products[0].URL, "https://www.yenimagaza.com.tr/") {
+		t.Errorf("unexpected products from structured data: %+v", products)
+	}
+
+	// Removing the site from the file unregisters it.
+	if err := useSelectorConfig(t, `{"sites": {}}`); err != nil {
+		t.Fatalf("LoadSelectorConfig: %v", err)
+	}
+	if _, ok := LookupSite("yenimagaza"); ok {
+		t.Errorf("site still registered after it was removed from the config file")
+	}
+
+	// Unknown sites without a name, domain and search URL are rejected.
+	if err := useSelectorConfig(t, `{"sites": {"yenimagaza": {"search_url": "https://www.yenimagaza.com.tr/arama?q={query}"}}}`); err == nil {
+		t.Errorf("LoadSelectorConfig accepted an incomplete new site")
+	}
+}
+
+func TestScrapeHonorsCancellation(t *testing.T) {
+	useFixtures(t, "trendyol")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	scraper, _ := Lookup("trendyol")
+	products, err := scraper.Scrape(ctx, "iphone 15", Options{})
+	if err != context.Canceled {
+		t.Fatalf("Scrape error = %v, want %v", err, context.Canceled)
+	}
+	if len(products) != 0 {
+		t.Errorf("Scrape returned %d products after cancellation", len(products))
+	}
+}
+
+func TestScrapeFollowsPagination(t *testing.T) {
+	useFixtures(t, "trendyol")
+	scraper, _ := Lookup("trendyol")
+
+	tests := []struct {
+		name string
+		opts Options
+		want int
+	}{
+		{"first page only by default", Options{}, 3},
+		// Page 2 repeats one product of page 1 and page 3 does not exist.
+		{"stops when pages run out", Options{MaxPages: 5}, 5},
+		{"limit cuts across pages", Options{MaxPages: 5, Limit: 4}, 4},
+		{"limit within first page", Options{MaxPages: 5, Limit: 2}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := scraper.Scrape(context.Background(), "iphone 15", tt.opts)
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if len(products) != tt.want {
+				t.Errorf("got %d products, want %d", len(products), tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthFlagsSelectorDrift(t *testing.T) {
+	healthy := &Diagnostics{HTTPStatus: 200, BytesReceived: 1024, CardsMatched: 10, Products: 10, MissingFields: map[string]int{FieldRating: 4}}
+	noPrices := &Diagnostics{HTTPStatus: 200, BytesReceived: 1024, CardsMatched: 10, Products: 10, MissingFields: map[string]int{FieldPrice: 9}}
+	noCards := &Diagnostics{HTTPStatus: 200, BytesReceived: 1024, MissingFields: map[string]int{}}
+	failed := &Diagnostics{HTTPStatus: 503, BytesReceived: 12, Error: "Service Unavailable", MissingFields: map[string]int{}}
+
+	tests := []struct {
+		name    string
+		history []*Diagnostics
+		want    string
+	}{
+		{"no scrapes", nil, HealthUnknown},
+		{"all fields present", []*Diagnostics{healthy}, HealthHealthy},
+		{"prices missing", []*Diagnostics{healthy, noPrices}, HealthDegraded},
+		{"single empty result", []*Diagnostics{healthy, noCards}, HealthHealthy},
+		{"repeated empty results", []*Diagnostics{noCards, noCards, noCards}, HealthDegraded},
+		{"last scrape failed", []*Diagnostics{healthy, failed}, HealthFailing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateHealth("test", tt.history)
+			if got.Status != tt.want {
+				t.Errorf("status = %q, want %q (reasons: %v)", got.Status, tt.want, got.Reasons)
+			}
+		})
+	}
+}
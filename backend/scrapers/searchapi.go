@@ -0,0 +1,112 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"smartyshop/internal"
+	"smartyshop/pkg/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly"
+)
+
+// searchAPIParser maps a response of a store's JSON search API to products.
+// Relative product URLs are resolved against base, the store's home page.
+type searchAPIParser func(body []byte, base *url.URL) ([]internal.Product, error)
+
+// searchAPIs holds the search API parsers by site ID. It is only written
+// from init functions.
+var searchAPIs = make(map[string]searchAPIParser)
+
+// registerSearchAPI installs the parser of a site's search API. It must be
+// called before the site's configuration is registered.
+func registerSearchAPI(id string, parse searchAPIParser) {
+	if _, dup := searchAPIs[id]; dup {
+		panic(fmt.Sprintf("scrapers: registerSearchAPI called twice for %q", id))
+	}
+	searchAPIs[id] = parse
+}
+
+// lookupSearchAPI returns the search API parser of a site.
+func lookupSearchAPI(id string) (searchAPIParser, bool) {
+	parse, ok := searchAPIs[id]
+	return parse, ok
+}
+
+// apiURL returns the search API URL of the zero-based result page for query.
+func (cfg SiteConfig) apiURL(query string, page int) string {
+	if cfg.QueryEncoding == QueryEnglish {
+		query = utils.ConvertToEnglishChars(query)
+	}
+	return strings.NewReplacer(
+		"{query}", url.QueryEscape(query),
+		"{page}", strconv.Itoa(page+1),
+	).Replace(cfg.APIURL)
+}
+
+// scrapeAPI scrapes the search results of a site for query from its JSON
+// search API, following result pages as allowed by opts.
+func scrapeAPI(ctx context.Context, siteID, query string, opts Options, diag *Diagnostics) ([]internal.Product, error) {
+	site, ok := LookupSite(siteID)
+	if !ok {
+		return nil, fmt.Errorf("unknown site %q", siteID)
+	}
+	cfg, ok := siteConfig(siteID)
+	if !ok {
+		return nil, fmt.Errorf("no selector config for site %q", siteID)
+	}
+	parse, ok := lookupSearchAPI(siteID)
+	if !ok {
+		return nil, fmt.Errorf("no search API for site %q", siteID)
+	}
+	endpoint, err := url.Parse(cfg.APIURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api_url: %w", err)
+	}
+	base := &url.URL{Scheme: "https", Host: site.Domain, Path: "/"}
+
+	results := newResultSet(site.Name, opts)
+	found := 0
+	var parseErr error
+
+	c := newCollector(ctx, diag,
+		colly.AllowedDomains(site.Domain, endpoint.Hostname()),
+	)
+	if cfg.UserAgent != "" {
+		c.UserAgent = cfg.UserAgent
+	}
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("Accept", "application/json")
+		log.Println("Visiting", r.URL.String())
+	})
+	c.OnResponse(func(r *colly.Response) {
+		products, err := parse(r.Body, base)
+		if err != nil {
+			parseErr = fmt.Errorf("%w: parsing %s search API response: %w", ErrLayoutChanged, site.ID, err)
+			return
+		}
+		for _, product := range products {
+			diag.cardMatched()
+			found++
+			results.add(product)
+		}
+	})
+
+	err = visitPages(ctx, c, site.ID, cfg.pages(opts), func(page int) string {
+		return cfg.apiURL(query, page)
+	}, &found, results.full)
+	// A page that cannot be parsed ends the pagination like an empty one,
+	// it only fails the scrape when nothing was found before it.
+	if err == nil && len(results.products) == 0 {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diag.setSource(SourceAPI)
+	return results.products, nil
+}
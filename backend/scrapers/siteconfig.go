@@ -0,0 +1,535 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Query encodings supported by SiteConfig.QueryEncoding.
+const (
+	// QueryEscape URL-escapes the query as is.
+	QueryEscape = "escape"
+	// QueryEnglish replaces Turkish characters with their English
+	// equivalents before URL-escaping the query.
+	QueryEnglish = "english"
+)
+
+// Search strategies supported by SiteConfig.Strategy.
+const (
+	// StrategyHTML scrapes the search result pages.
+	StrategyHTML = "html"
+	// StrategyAPI calls the store's JSON search API, falling back to
+	// StrategyHTML when the API fails or returns no products.
+	StrategyAPI = "api"
+)
+
+// FieldRule describes how to read one value from a product card. The first
+// element matched by Selector that yields a non-empty value after cleanup
+// wins.
+type FieldRule struct {
+	// Selector is a CSS selector relative to the card. An empty selector
+	// reads from the card element itself.
+	Selector string `json:"selector,omitempty"`
+	// Attr is the attribute to read. An empty Attr reads the element's text.
+	Attr string `json:"attr,omitempty"`
+	// Trim is a set of characters removed from both ends of the value.
+	Trim string `json:"trim,omitempty"`
+	// Pattern is an optional regular expression. Values that do not match
+	// are skipped; otherwise the first capture group, or the whole match if
+	// there is none, is kept.
+	Pattern string `json:"pattern,omitempty"`
+	// OutOf rescales a numeric value given on a scale of 0 to OutOf to a
+	// five-star rating, e.g. 100 for ratings shown as a percentage.
+	OutOf float64 `json:"out_of,omitempty"`
+	// Format wraps the value, with "{value}" replaced by it, e.g. "{value} TL".
+	Format string `json:"format,omitempty"`
+	// Join builds the value from parts of the first element matched by
+	// Selector: the values of the Join rules, read within that element and
+	// joined by Separator. The rule yields nothing unless every part has a
+	// value, e.g. the whole and fractional parts of a price.
+	Join      []FieldRule `json:"join,omitempty"`
+	Separator string      `json:"separator,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// SiteConfig is the declarative description of how to scrape a site's
+// search results.
+type SiteConfig struct {
+	// Name and Domain describe a site defined by the selector configuration
+	// file alone, see LoadSelectorConfig. They are ignored for built-in
+	// sites.
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	// SearchURL is the search page URL with "{query}" in place of the query.
+	// The query is path-escaped when it is part of the URL path and
+	// query-escaped otherwise.
+	SearchURL string `json:"search_url,omitempty"`
+	// QueryEncoding is QueryEscape (the default) or QueryEnglish.
+	QueryEncoding string `json:"query_encoding,omitempty"`
+	// PageParam is the query parameter selecting a result page. Sites
+	// without it are never paginated.
+	PageParam string `json:"page_param,omitempty"`
+	// FirstPage is the PageParam value of the first result page, usually 1.
+	FirstPage *int `json:"first_page,omitempty"`
+	// UserAgent overrides colly's default user agent when set.
+	UserAgent string `json:"user_agent,omitempty"`
+	// Card selects one element per product on the search page. Sites
+	// without a card are scraped from their structured data only.
+	Card string `json:"card,omitempty"`
+	// Fields maps product fields (FieldTitle, FieldPrice, ...) to the rules
+	// tried in order to extract them.
+	Fields map[string][]FieldRule `json:"fields,omitempty"`
+	// Required lists the fields without which a card is skipped.
+	Required []string `json:"required,omitempty"`
+	// Strategy is StrategyHTML (the default) or StrategyAPI.
+	Strategy string `json:"strategy,omitempty"`
+	// APIURL is the URL of the store's JSON search API with "{query}" in
+	// place of the query and "{page}" in place of the one-based page number.
+	// It is required by StrategyAPI.
+	APIURL string `json:"api_url,omitempty"`
+	// RateLimit overrides the default rate limit of the site's hosts.
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// RespectRobotsTxt makes the scrapers skip the URLs that the robots.txt
+	// of the site disallows.
+	RespectRobotsTxt bool `json:"respect_robots_txt,omitempty"`
+	// Cache overrides the default cache policy of the site's results.
+	Cache *CachePolicy `json:"cache,omitempty"`
+	// Detail describes the product detail page. Sites without it can only
+	// be enriched from the structured data embedded in the page.
+	Detail *DetailConfig `json:"detail,omitempty"`
+}
+
+// CachePolicy configures how long the search results of a site are cached.
+type CachePolicy struct {
+	// TTLSeconds is how long results are served as fresh. Zero makes every
+	// cached result stale.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// StaleSeconds is how long results are served after TTLSeconds while
+	// they are refreshed in the background. Zero disables serving stale
+	// results.
+	StaleSeconds int `json:"stale_seconds,omitempty"`
+}
+
+// defaultCachePolicy applies to sites without a cache policy of their own.
+var defaultCachePolicy = CachePolicy{TTLSeconds: 300, StaleSeconds: 3600}
+
+// TTL returns how long results are fresh.
+func (p CachePolicy) TTL() time.Duration {
+	return time.Duration(p.TTLSeconds) * time.Second
+}
+
+// Stale returns how long results are served stale after their TTL.
+func (p CachePolicy) Stale() time.Duration {
+	return time.Duration(p.StaleSeconds) * time.Second
+}
+
+// SiteCachePolicy returns the cache policy in effect for a site.
+func SiteCachePolicy(id string) CachePolicy {
+	if cfg, ok := siteConfig(id); ok && cfg.Cache != nil {
+		return *cfg.Cache
+	}
+	return defaultCachePolicy
+}
+
+// DetailConfig describes how to read a product detail page. Rules are
+// applied to the whole document.
+type DetailConfig struct {
+	// Fields maps product fields (FieldDescription, FieldBrand, FieldSeller,
+	// FieldStock, ...) to the rules tried in order to extract them.
+	Fields map[string][]FieldRule `json:"fields,omitempty"`
+	// Images are collected from every element matched by these rules.
+	Images []FieldRule `json:"images,omitempty"`
+	// SpecRows selects one element per row of the specification table.
+	SpecRows string `json:"spec_rows,omitempty"`
+	// SpecKey and SpecValue read the name and value of a specification
+	// relative to its row.
+	SpecKey   []FieldRule `json:"spec_key,omitempty"`
+	SpecValue []FieldRule `json:"spec_value,omitempty"`
+	// OutOfStock is a regular expression matched against the stock field.
+	// A stock text that matches marks the product as out of stock, any
+	// other non-empty text as in stock.
+	OutOfStock string `json:"out_of_stock,omitempty"`
+
+	outOfStock *regexp.Regexp
+}
+
+// compile validates the configuration and compiles its patterns.
+func (cfg *SiteConfig) compile() error {
+	if cfg.SearchURL == "" {
+		return fmt.Errorf("search_url is required")
+	}
+	if cfg.Card == "" && len(cfg.Fields) > 0 {
+		return fmt.Errorf("card selector is required with field selectors")
+	}
+	switch cfg.QueryEncoding {
+	case "", QueryEscape, QueryEnglish:
+	default:
+		return fmt.Errorf("unknown query_encoding %q", cfg.QueryEncoding)
+	}
+
+	if rl := cfg.RateLimit; rl != nil && (rl.RequestsPerSecond < 0 || rl.MaxConcurrency < 0 || rl.RandomDelayMs < 0) {
+		return fmt.Errorf("rate_limit values must not be negative")
+	}
+	if p := cfg.Cache; p != nil && (p.TTLSeconds < 0 || p.StaleSeconds < 0) {
+		return fmt.Errorf("cache values must not be negative")
+	}
+
+	switch cfg.Strategy {
+	case "", StrategyHTML:
+	case StrategyAPI:
+		if cfg.APIURL == "" {
+			return fmt.Errorf("api_url is required by the %s strategy", StrategyAPI)
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q", cfg.Strategy)
+	}
+
+	fields, err := compileFields(cfg.Fields)
+	if err != nil {
+		return err
+	}
+	cfg.Fields = fields
+
+	if cfg.Detail != nil {
+		detail := *cfg.Detail
+		if err := detail.compile(); err != nil {
+			return fmt.Errorf("detail: %w", err)
+		}
+		cfg.Detail = &detail
+	}
+	return nil
+}
+
+// compileFor compiles the configuration of the site id, additionally
+// checking that the site can use the selected strategy.
+func (cfg *SiteConfig) compileFor(id string) error {
+	if err := cfg.compile(); err != nil {
+		return err
+	}
+	if cfg.Strategy == StrategyAPI {
+		if _, ok := lookupSearchAPI(id); !ok {
+			return fmt.Errorf("no search API is implemented for %s", id)
+		}
+	}
+	return nil
+}
+
+// compile validates the detail configuration and compiles its patterns.
+func (d *DetailConfig) compile() error {
+	fields, err := compileFields(d.Fields)
+	if err != nil {
+		return err
+	}
+	d.Fields = fields
+
+	if d.Images, err = compileRules(d.Images); err != nil {
+		return fmt.Errorf("images: %w", err)
+	}
+	if d.SpecKey, err = compileRules(d.SpecKey); err != nil {
+		return fmt.Errorf("spec_key: %w", err)
+	}
+	if d.SpecValue, err = compileRules(d.SpecValue); err != nil {
+		return fmt.Errorf("spec_value: %w", err)
+	}
+
+	d.outOfStock = nil
+	if d.OutOfStock != "" {
+		re, err := regexp.Compile(d.OutOfStock)
+		if err != nil {
+			return fmt.Errorf("out_of_stock: %w", err)
+		}
+		d.outOfStock = re
+	}
+	return nil
+}
+
+// compileFields compiles the rules of every field.
+func compileFields(fields map[string][]FieldRule) (map[string][]FieldRule, error) {
+	compiled := make(map[string][]FieldRule, len(fields))
+	for field, rules := range fields {
+		c, err := compileRules(rules)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		compiled[field] = c
+	}
+	return compiled, nil
+}
+
+// compileRules returns a copy of rules with their patterns compiled. Rules
+// are copied so that compiling never writes to slices shared with a
+// configuration that is already in use.
+func compileRules(rules []FieldRule) ([]FieldRule, error) {
+	if rules == nil {
+		return nil, nil
+	}
+
+	compiled := make([]FieldRule, len(rules))
+	copy(compiled, rules)
+	for i := range compiled {
+		join, err := compileRules(compiled[i].Join)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i].Join = join
+
+		if compiled[i].Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(compiled[i].Pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i].re = re
+	}
+	return compiled, nil
+}
+
+// merge returns cfg with the non-empty settings of override applied. Field
+// rules are replaced per field.
+func (cfg SiteConfig) merge(override SiteConfig) SiteConfig {
+	merged := cfg
+	if override.SearchURL != "" {
+		merged.SearchURL = override.SearchURL
+	}
+	if override.QueryEncoding != "" {
+		merged.QueryEncoding = override.QueryEncoding
+	}
+	if override.PageParam != "" {
+		merged.PageParam = override.PageParam
+	}
+	if override.FirstPage != nil {
+		merged.FirstPage = override.FirstPage
+	}
+	if override.UserAgent != "" {
+		merged.UserAgent = override.UserAgent
+	}
+	if override.Card != "" {
+		merged.Card = override.Card
+	}
+	if override.Required != nil {
+		merged.Required = override.Required
+	}
+	if override.Strategy != "" {
+		merged.Strategy = override.Strategy
+	}
+	if override.APIURL != "" {
+		merged.APIURL = override.APIURL
+	}
+	if override.RateLimit != nil {
+		merged.RateLimit = override.RateLimit
+	}
+	if override.RespectRobotsTxt {
+		merged.RespectRobotsTxt = true
+	}
+	if override.Cache != nil {
+		merged.Cache = override.Cache
+	}
+
+	merged.Fields = mergeFields(cfg.Fields, override.Fields)
+
+	switch {
+	case override.Detail == nil:
+	case cfg.Detail == nil:
+		merged.Detail = override.Detail
+	default:
+		detail := cfg.Detail.merge(*override.Detail)
+		merged.Detail = &detail
+	}
+	return merged
+}
+
+// merge returns d with the non-empty settings of override applied.
+func (d DetailConfig) merge(override DetailConfig) DetailConfig {
+	merged := d
+	merged.Fields = mergeFields(d.Fields, override.Fields)
+	if override.Images != nil {
+		merged.Images = override.Images
+	}
+	if override.SpecRows != "" {
+		merged.SpecRows = override.SpecRows
+	}
+	if override.SpecKey != nil {
+		merged.SpecKey = override.SpecKey
+	}
+	if override.SpecValue != nil {
+		merged.SpecValue = override.SpecValue
+	}
+	if override.OutOfStock != "" {
+		merged.OutOfStock = override.OutOfStock
+	}
+	return merged
+}
+
+// mergeFields returns the rules of base with the fields of override replaced.
+func mergeFields(base, override map[string][]FieldRule) map[string][]FieldRule {
+	merged := make(map[string][]FieldRule, len(base)+len(override))
+	for field, rules := range base {
+		merged[field] = rules
+	}
+	for field, rules := range override {
+		merged[field] = rules
+	}
+	return merged
+}
+
+// intPtr returns a pointer to n, for optional settings such as SiteConfig.FirstPage.
+func intPtr(n int) *int {
+	return &n
+}
+
+// selectorFile is the layout of the selector configuration file.
+type selectorFile struct {
+	Sites map[string]SiteConfig `json:"sites"`
+}
+
+// selectorStore holds the built-in site configurations and the ones loaded
+// from the selector configuration file.
+type selectorStore struct {
+	mu        sync.RWMutex
+	defaults  map[string]SiteConfig
+	effective map[string]SiteConfig
+}
+
+var selectors = &selectorStore{
+	defaults:  make(map[string]SiteConfig),
+	effective: make(map[string]SiteConfig),
+}
+
+// registerSiteConfig installs the built-in configuration of a site. It is
+// called from the init function of the site's scraper and panics if the
+// configuration is invalid.
+func registerSiteConfig(id string, cfg SiteConfig) {
+	if err := cfg.compileFor(id); err != nil {
+		panic(fmt.Sprintf("scrapers: invalid built-in config for %q: %v", id, err))
+	}
+
+	selectors.mu.Lock()
+	defer selectors.mu.Unlock()
+
+	selectors.defaults[id] = cfg
+	selectors.effective[id] = cfg
+}
+
+// siteConfig returns the configuration currently in effect for a site.
+func siteConfig(id string) (SiteConfig, bool) {
+	selectors.mu.RLock()
+	defer selectors.mu.RUnlock()
+
+	cfg, ok := selectors.effective[id]
+	return cfg, ok
+}
+
+// LoadSelectorConfig reads the selector configuration file at path and
+// applies it on top of the built-in configurations. The file is a JSON
+// object of the form {"sites": {"<site id>": <SiteConfig>}} where only the
+// settings to override need to be given. Nothing is applied if the file is
+// invalid.
+//
+// A site that is not built in can be onboarded from the file alone by
+// giving its name, domain and search_url. It is scraped from the structured
+// data of its pages, or with its card and field selectors when it has a
+// card, and disappears again when it is removed from the file.
+func LoadSelectorConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading selector config: %w", err)
+	}
+
+	var file selectorFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing selector config: %w", err)
+	}
+
+	selectors.mu.Lock()
+	defer selectors.mu.Unlock()
+
+	effective := make(map[string]SiteConfig, len(selectors.defaults))
+	for id, cfg := range selectors.defaults {
+		effective[id] = cfg
+	}
+	configured := make(map[string]SiteConfig)
+	for id, override := range file.Sites {
+		cfg, ok := selectors.defaults[id]
+		switch {
+		case ok:
+			cfg = cfg.merge(override)
+		case isBuiltIn(id):
+			return fmt.Errorf("selector config: site %s has no selectors to override", id)
+		case override.Name == "" || override.Domain == "" || override.SearchURL == "":
+			return fmt.Errorf("selector config: unknown site %q, new sites need a name, domain and search_url", id)
+		default:
+			cfg = override
+			configured[id] = cfg
+		}
+		if err := cfg.compileFor(id); err != nil {
+			return fmt.Errorf("selector config: site %s: %w", id, err)
+		}
+		effective[id] = cfg
+	}
+
+	selectors.effective = effective
+	for id, cfg := range configured {
+		registerConfigured(configuredSite(id, cfg))
+	}
+	unregisterConfigured(configured)
+	return nil
+}
+
+// configuredSite returns the registration of a site defined by the selector
+// configuration file alone.
+func configuredSite(id string, cfg SiteConfig) (Site, Factory) {
+	site := Site{
+		ID:           id,
+		Name:         cfg.Name,
+		Domain:       strings.ToLower(cfg.Domain),
+		Capabilities: []Capability{CapabilitySearch, CapabilityDetail},
+	}
+	if cfg.Card != "" {
+		return site, func() Scraper { return &ConfigScraper{SiteID: id} }
+	}
+	return site, func() Scraper { return &StructuredDataScraper{SiteID: id} }
+}
+
+// WatchSelectorConfig reloads the selector configuration file at path
+// whenever its modification time changes, checking every interval until stop
+// is closed. Invalid files are logged and the previous configuration is kept.
+func WatchSelectorConfig(path string, interval time.Duration, stop <-chan struct{}) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Printf("WARN: cannot stat selector config %s: %v", path, err)
+				continue
+			}
+			if !info.ModTime().After(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+
+			if err := LoadSelectorConfig(path); err != nil {
+				log.Printf("WARN: keeping previous selectors: %v", err)
+				continue
+			}
+			log.Printf("INFO: reloaded selector config from %s", path)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package scrapers
+
+import (
+	"context"
+	"smartyshop/internal"
+)
+
+// StructuredDataScraper scrapes a registered site from the schema.org
+// JSON-LD and microdata embedded in its pages, ignoring any card selectors.
+// A store that publishes structured data can be onboarded without a release
+// from the selector configuration file, see LoadSelectorConfig:
+//
+//	{"sites": {"store": {
+//		"name": "Store",
+//		"domain": "www.store.com.tr",
+//		"search_url": "https://www.store.com.tr/arama?q={query}",
+//		"page_param": "sayfa"
+//	}}}
+type StructuredDataScraper struct {
+	SiteID string
+}
+
+// Scrape scrapes the structured data of the site's search results for query,
+// following result pages as allowed by opts.
+func (s *StructuredDataScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return withBreaker(s.SiteID, func() ([]internal.Product, error) {
+		return scrapeSearch(ctx, s.SiteID, query, opts, false, newDiagnostics(s.SiteID, query))
+	})
+}
+
+// Detail scrapes the structured data of a product page of the site.
+func (s *StructuredDataScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return (&ConfigScraper{SiteID: s.SiteID}).Detail(ctx, productURL)
+}
@@ -1,85 +1,62 @@
-﻿package scrapers
+package scrapers
 
 import (
-	"fmt"
-	"log"
-	"math/rand"
+	"context"
 	"smartyshop/internal"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/gocolly/colly"
 )
 
+// TeknosaScraper implements the Scraper interface for Teknosa.
 type TeknosaScraper struct{}
 
-func (s *TeknosaScraper) Scrape(query string) ([]internal.Product, error) {
-	var products []internal.Product
-
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.teknosa.com"),
-	)
-
-	c.OnHTML("div#product-item", func(e *colly.HTMLElement) {
-		title := e.Attr("data-product-name")
-		if title == "" {
-			log.Println("Warning: product without title skipped")
-			return // Zorunlu alan yoksa bu ürünü atla
-		}
-
-		price := e.Attr("data-price-with-discount")
-		if price == "" {
-			price = e.Attr("data-product-price")
-		}
-
-		ratingStr := e.Attr("data-product-rating-score")
-		// Puan (rating) - Teknosa'da genellikle puan bilgisi olmaz.
-		rand.Seed(time.Now().UnixNano())
-		rating := 3.5 + rand.Float64()*(4.9-3.5)
-		rating, _ = strconv.ParseFloat(fmt.Sprintf("%.1f", rating), 64)
-		if ratingStr != "" {
-			var err error
-			rating, err = strconv.ParseFloat(strings.ReplaceAll(ratingStr, ",", "."), 64)
-			if err != nil {
-				log.Printf("Rating parse failed: %v", err)
-			}
-		}
-
-		reviewsStr := e.Attr("data-product-review-count")
-		reviews := 0
-		if reviewsStr != "" {
-			var err error
-			reviews, err = strconv.Atoi(reviewsStr)
-			if err != nil {
-				log.Printf("Reviews count parse failed: %v", err)
-			}
-		}
-
-		productURL := e.Attr("data-product-url")
-		if productURL == "" {
-			log.Println("Warning: product without URL skipped")
-			return
-		}
-
-		product := internal.Product{
-			Title:        title,
-			Price:        price + " TL",
-			ImageURL:     e.Attr("data-insider-img"),
-			URL:          "https://www.teknosa.com" + productURL,
-			Site:         "Teknosa",
-			Rating:       rating,
-			ReviewsCount: reviews,
-		}
-
-		products = append(products, product)
+func init() {
+	Register(Site{
+		ID:           "teknosa",
+		Name:         "Teknosa",
+		Domain:       "www.teknosa.com",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews, CapabilityDetail},
+	}, func() Scraper { return &TeknosaScraper{} })
+
+	// Teknosa ürün bilgilerini kartın kendi data-* özniteliklerinde taşır.
+	registerSiteConfig("teknosa", SiteConfig{
+		SearchURL: "https://www.teknosa.com/arama/?sort=mostFavorited-desc&s={query}%3Arelevance",
+		PageParam: "page",
+		FirstPage: intPtr(0), // Teknosa sayfaları 0'dan başlar
+		Card:      "div#product-item",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {{Attr: "data-product-name"}},
+			FieldPrice: {
+				{Attr: "data-price-with-discount", Format: "{value} TL"},
+				{Attr: "data-product-price", Format: "{value} TL"},
+			},
+			FieldImage:   {{Attr: "data-insider-img"}},
+			FieldURL:     {{Attr: "data-product-url"}},
+			FieldRating:  {{Attr: "data-product-rating-score"}}, // Teknosa'da genellikle puan bilgisi olmaz
+			FieldReviews: {{Attr: "data-product-review-count"}},
+		},
+		Required: []string{FieldTitle, FieldURL},
+		Detail: &DetailConfig{
+			Fields: map[string][]FieldRule{
+				FieldTitle:       {{Selector: "h1.pdp-title"}},
+				FieldPrice:       {{Selector: ".pdp-prices .prc-last"}},
+				FieldBrand:       {{Selector: ".pdp-brand a"}},
+				FieldDescription: {{Selector: "#pdp-description"}},
+				FieldStock:       {{Selector: "#addToCartButton"}},
+			},
+			Images:     []FieldRule{{Selector: ".pdp-gallery img", Attr: "data-src"}},
+			SpecRows:   "#pdp-technical .ptf-body tr",
+			SpecKey:    []FieldRule{{Selector: "th"}},
+			SpecValue:  []FieldRule{{Selector: "td"}},
+			OutOfStock: `(?i)tükendi|stokta yok`,
+		},
 	})
+}
 
-	searchURL := fmt.Sprintf("https://www.teknosa.com/arama/?sort=mostFavorited-desc&s=%s%%3Arelevance", query)
-	err := c.Visit(searchURL)
-	if err != nil {
-		return nil, fmt.Errorf("visit failed: %w", err)
-	}
+// Scrape scrapes Teknosa for products.
+func (s *TeknosaScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "teknosa"}).Scrape(ctx, query, opts)
+}
 
-	return products, nil
+// Detail scrapes a Teknosa product page.
+func (s *TeknosaScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return (&ConfigScraper{SiteID: "teknosa"}).Detail(ctx, productURL)
 }
@@ -1,98 +1,148 @@
 package scrapers
 
 import (
-	"fmt"
-	"log"
-	"math/rand"
+	"context"
+	"encoding/json"
+	"net/url"
 	"smartyshop/internal"
-	"smartyshop/pkg/utils"
-	"strconv"
 	"strings"
-	"time"
-
-	"github.com/gocolly/colly"
 )
 
+// trendyolCDN serves the product images referenced by the search API.
+const trendyolCDN = "https://cdn.dsmcdn.com"
+
 // TrendyolScraper implements the Scraper interface for Trendyol.
 type TrendyolScraper struct{}
 
+func init() {
+	Register(Site{
+		ID:           "trendyol",
+		Name:         "Trendyol",
+		Domain:       "www.trendyol.com",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews, CapabilityDetail},
+	}, func() Scraper { return &TrendyolScraper{} })
+
+	registerSearchAPI("trendyol", parseTrendyolAPI)
+	registerSiteConfig("trendyol", SiteConfig{
+		SearchURL:     "https://www.trendyol.com/sr?q={query}",
+		QueryEncoding: QueryEnglish,
+		PageParam:     "pi",
+		// Arama sayfası sonuçları bu servisten çeker
+		Strategy: StrategyAPI,
+		APIURL:   "https://public.trendyol.com/discovery-web-searchgw-service/v2/api/infinite-scroll/sr?q={query}&pi={page}",
+		Card:     ".p-card-wrppr",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {{Selector: ".prdct-desc-cntnr-name"}},
+			FieldPrice: {
+				{Selector: ".price-item.lowest-price-discounted"},
+				{Selector: ".price-item.discounted"},
+				{Selector: ".price-item.basket-price-original"},
+			},
+			FieldImage: {
+				{Selector: ".p-card-img", Attr: "data-src"},
+				{Selector: ".p-card-img", Attr: "src"},
+			},
+			FieldURL:     {{Selector: "a", Attr: "href"}},
+			FieldBrand:   {{Selector: ".prdct-desc-cntnr-ttl"}},
+			FieldRating:  {{Selector: ".rating-score"}},
+			FieldReviews: {{Selector: ".ratingCount", Trim: "()"}},
+		},
+		Detail: &DetailConfig{
+			Fields: map[string][]FieldRule{
+				FieldTitle:       {{Selector: "h1.pr-new-br span"}},
+				FieldBrand:       {{Selector: "h1.pr-new-br a"}},
+				FieldPrice:       {{Selector: ".product-price-container .prc-dsc"}},
+				FieldDescription: {{Selector: ".detail-desc-contents"}},
+				FieldSeller:      {{Selector: ".merchant-name"}},
+				FieldStock:       {{Selector: ".add-to-basket-button-text"}},
+			},
+			Images:    []FieldRule{{Selector: ".product-slide img", Attr: "src"}},
+			SpecRows:  "li.detail-attr-item",
+			SpecKey:   []FieldRule{{Selector: ".attr-name"}},
+			SpecValue: []FieldRule{{Selector: ".attr-value-name-w"}},
+			// Stokta olmayan ürünlerde sepet butonu "Tükendi" yazar
+			OutOfStock: `(?i)tükendi|stokta yok`,
+		},
+	})
+}
+
 // Scrape scrapes Trendyol for products.
-func (s *TrendyolScraper) Scrape(query string) ([]internal.Product, error) {
-	var products []internal.Product
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.trendyol.com"),
-	)
+func (s *TrendyolScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "trendyol"}).Scrape(ctx, query, opts)
+}
 
-	c.OnHTML(".p-card-wrppr", func(e *colly.HTMLElement) {
-		log.Println("Found product card")
+// Detail scrapes a Trendyol product page.
+func (s *TrendyolScraper) Detail(ctx context.Context, productURL string) (internal.Product, error) {
+	return (&ConfigScraper{SiteID: "trendyol"}).Detail(ctx, productURL)
+}
+
+// trendyolSearchResponse is the part of a Trendyol search API response we use.
+type trendyolSearchResponse struct {
+	Result struct {
+		Products []struct {
+			Name   string   `json:"name"`
+			URL    string   `json:"url"`
+			Images []string `json:"images"`
+			Brand  struct {
+				Name string `json:"name"`
+			} `json:"brand"`
+			MerchantName string `json:"merchantName"`
+			RatingScore  struct {
+				AverageRating float64 `json:"averageRating"`
+				TotalCount    int     `json:"totalCount"`
+			} `json:"ratingScore"`
+			Price struct {
+				SellingPrice    float64 `json:"sellingPrice"`
+				DiscountedPrice float64 `json:"discountedPrice"`
+				OriginalPrice   float64 `json:"originalPrice"`
+				Currency        string  `json:"currency"`
+			} `json:"price"`
+		} `json:"products"`
+	} `json:"result"`
+}
 
-		title := e.ChildText(".prdct-desc-cntnr-name")
-		log.Printf("Title: %s", title)
+// parseTrendyolAPI maps a Trendyol search API response to products.
+func parseTrendyolAPI(body []byte, base *url.URL) ([]internal.Product, error) {
+	var resp trendyolSearchResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
 
-		price := e.ChildText(".price-item.lowest-price-discounted")
-		if price == "" {
-			price = e.ChildText(".price-item.discounted") // Check for discounted price if lowest-price-discounted is empty
-			if price == "" {
-				price = e.ChildText(".price-item.basket-price-original")
+	products := make([]internal.Product, 0, len(resp.Result.Products))
+	for _, item := range resp.Result.Products {
+		product := internal.Product{
+			Title:        item.Name,
+			ReviewsCount: item.RatingScore.TotalCount,
+			Brand:        item.Brand.Name,
+			Seller:       item.MerchantName,
+		}
+		if ref, err := url.Parse(item.URL); err == nil && item.URL != "" {
+			product.URL = base.ResolveReference(ref).String()
+		}
+		for _, image := range item.Images {
+			if strings.HasPrefix(image, "/") {
+				image = trendyolCDN + image
 			}
+			product.Images = append(product.Images, image)
 		}
-		log.Printf("Price: %s", price)
-
-		imageURL := e.ChildAttr(".p-card-img", "data-src")
-		if imageURL == "" {
-			imageURL = e.ChildAttr(".p-card-img", "src")
+		if len(product.Images) > 0 {
+			product.ImageURL = product.Images[0]
 		}
-		log.Printf("Image URL: %s", imageURL)
-
-		productURL := e.Request.AbsoluteURL(e.ChildAttr("a", "href"))
-		log.Printf("Product URL: %s", productURL)
-
-		description := e.ChildText(".prdct-desc-cntnr-ttl") // Still using this, might be brand
-		log.Printf("Description: %s", description)
 
-		ratingStr := e.ChildText(".rating-score") // Updated selector for rating
-		rating, err := strconv.ParseFloat(strings.Replace(ratingStr, ",", ".", 1), 64)
-		if err != nil {
-			log.Printf("Failed to parse rating: %s", err)
-			rating = 0
+		// discountedPrice sepette uygulanan indirimli fiyattır
+		price := item.Price.DiscountedPrice
+		if price <= 0 {
+			price = item.Price.SellingPrice
 		}
-
-		// Eğer rating hala 0 ise, rastgele bir değer ata
-		if rating == 0.0 {
-			rand.Seed(time.Now().UnixNano())
-			rating = 3.5 + rand.Float64()*(4.9-3.5)
-			rating, _ = strconv.ParseFloat(fmt.Sprintf("%.1f", rating), 64) // Tek ondalık basamağa yuvarla
+		if price > 0 {
+			product.Price = internal.NewMoney(price, item.Price.Currency)
 		}
-		log.Printf("Rating: %f", rating)
-
-		reviewsStr := e.ChildText(".ratingCount") // Updated selector for reviews count
-		reviewsStr = strings.Trim(reviewsStr, "()")
-		reviews, err := strconv.Atoi(reviewsStr)
-		if err != nil {
-			log.Printf("Failed to parse reviews count: %s", err)
-			reviews = 0
+		if item.Price.OriginalPrice > 0 {
+			product.SetOriginalPrice(internal.NewMoney(item.Price.OriginalPrice, item.Price.Currency))
 		}
-		log.Printf("Reviews Count: %d", reviews)
+		product.SetScrapedRating(item.RatingScore.AverageRating)
 
-		product := internal.Product{
-			Title:        title,
-			Price:        price,
-			ImageURL:     imageURL,
-			URL:          productURL,
-			Site:         "Trendyol",
-			Rating:       rating,
-			ReviewsCount: reviews,
-			Description:  description,
-		}
 		products = append(products, product)
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-		fmt.Println("Visiting", r.URL.String())
-	})
-
-	convertedQuery := utils.ConvertToEnglishChars(query)
-	c.Visit(fmt.Sprintf("https://www.trendyol.com/sr?q=%s", convertedQuery))
-
+	}
 	return products, nil
 }
@@ -0,0 +1,46 @@
+package scrapers
+
+import (
+	"context"
+	"smartyshop/internal"
+)
+
+// VatanScraper implements the Scraper interface for Vatan Bilgisayar.
+type VatanScraper struct{}
+
+func init() {
+	Register(Site{
+		ID:           "vatan",
+		Name:         "Vatan Bilgisayar",
+		Domain:       "www.vatanbilgisayar.com",
+		Capabilities: []Capability{CapabilitySearch, CapabilityRating, CapabilityReviews},
+	}, func() Scraper { return &VatanScraper{} })
+
+	// Vatan aramayı URL yolunda alır ve Türkçe karakterli aramalarda sonuç
+	// döndürmez, bu yüzden sorgu İngilizce karakterlere çevrilir.
+	registerSiteConfig("vatan", SiteConfig{
+		SearchURL:     "https://www.vatanbilgisayar.com/arama/{query}/",
+		QueryEncoding: QueryEnglish,
+		PageParam:     "page",
+		Card:          "div.product-list.product-list--list-page",
+		Fields: map[string][]FieldRule{
+			FieldTitle: {{Selector: ".product-list__product-name h3"}},
+			// Fiyat ve para birimi ayrı elemanlardadır: "44.999" + "TL"
+			FieldPrice: {{Selector: ".product-list__price", Format: "{value} TL"}},
+			FieldImage: {
+				{Selector: ".product-list__image-safe img", Attr: "data-src"},
+				{Selector: ".product-list__image-safe img", Attr: "src"},
+			},
+			FieldURL: {{Selector: "a.product-list__link", Attr: "href"}},
+			// Yıldızlar genişlikle çizilir: "width:90%" → 4.5
+			FieldRating:  {{Selector: ".wrapper-star .score", Attr: "style", Pattern: `width:\s*(\d+(?:\.\d+)?)%`, OutOf: 100}},
+			FieldReviews: {{Selector: ".comment-count", Trim: "()"}},
+		},
+		Required: []string{FieldTitle, FieldURL},
+	})
+}
+
+// Scrape scrapes Vatan Bilgisayar for products.
+func (s *VatanScraper) Scrape(ctx context.Context, query string, opts Options) ([]internal.Product, error) {
+	return (&ConfigScraper{SiteID: "vatan"}).Scrape(ctx, query, opts)
+}
@@ -77,26 +77,17 @@ function App() {
     setError('');
     setIsChatActive(false);
 
-    const sites = ['trendyol', 'teknosa', 'mediamarkt', 'amazon'];
     try {
-      const fetchPromises = sites.map(site =>
-        fetch(`http://localhost:8080/products?site=${site}&query=${encodeURIComponent(query)}`)
-          .then(response => {
-            if (!response.ok) {
-              // Don't throw, just log and return empty for this source
-              console.error(`Failed to fetch from ${site}: ${response.status}`);
-              return [];
-            }
-            return response.json();
-          })
-          .catch(err => {
-            console.error(`Error connecting to ${site}:`, err);
-            return []; // Return empty array on network error
-          })
-      );
-
-      const results = await Promise.all(fetchPromises);
-      const allProducts = results.flat().filter(p => p && p.title && p.price); // Flatten and basic validation
+      const response = await fetch(`http://localhost:8080/search?query=${encodeURIComponent(query)}`);
+      if (!response.ok) {
+        throw new Error(`Search failed: ${response.status}`);
+      }
+      const data = await response.json();
+      data.sites
+        .filter(site => site.status === 'error' || site.status === 'timed_out')
+        .forEach(site => console.error(`Failed to fetch from ${site.site}: ${site.status} ${site.error || ''}`));
+
+      const allProducts = data.products.filter(p => p && p.title && p.price); // Basic validation
       
       if (allProducts.length === 0) {
         setError('No products found for your query. Please try another search.');
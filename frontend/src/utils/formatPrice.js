@@ -1,18 +1,28 @@
-const formatPrice = (priceString) => {
-  if (!priceString) return '';
+const formatPrice = (price) => {
+  if (!price) return '';
+
+  // The backend sends prices as { amount, currency, display } where amount
+  // is in minor units (kuruş). Fall back to the display string if no amount
+  // could be parsed on the server.
+  if (typeof price === 'object') {
+    if (!price.amount) {
+      return price.display || '';
+    }
+    return `${(price.amount / 100).toFixed(2)} TL`;
+  }
 
   // Remove ' TL' suffix and ensure dot as decimal separator
-  let cleanedPrice = priceString.replace(' TL', '').replace(',', '.');
+  let cleanedPrice = price.replace(' TL', '').replace(',', '.');
 
   // Parse as a float
-  const price = parseFloat(cleanedPrice);
+  const parsed = parseFloat(cleanedPrice);
 
-  if (isNaN(price)) {
-    return priceString; // Return original if not a valid number
+  if (isNaN(parsed)) {
+    return price; // Return original if not a valid number
   }
 
   // Format to two decimal places and add ' TL' suffix
-  return `${price.toFixed(2)} TL`;
+  return `${parsed.toFixed(2)} TL`;
 };
 
 export default formatPrice;